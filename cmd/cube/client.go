@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/codding-buddha/mini-kube/common"
+	"github.com/codding-buddha/mini-kube/cronjob"
+	"github.com/codding-buddha/mini-kube/deployment"
+	"github.com/codding-buddha/mini-kube/job"
+	"github.com/codding-buddha/mini-kube/manager"
+	"github.com/codding-buddha/mini-kube/node"
+	"github.com/codding-buddha/mini-kube/task"
+	"github.com/google/uuid"
+)
+
+// Client calls the manager API at Address.
+type Client struct {
+	Address string
+	HTTP    *http.Client
+}
+
+func NewClient(address string) *Client {
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+
+	return &Client{
+		Address: strings.TrimSuffix(address, "/"),
+		HTTP:    &http.Client{},
+	}
+}
+
+func (c *Client) Tasks() ([]*task.Task, error) {
+	var tasks []*task.Task
+	err := c.do(http.MethodGet, "/tasks", nil, &tasks)
+	return tasks, err
+}
+
+func (c *Client) Task(id uuid.UUID) (*task.Task, error) {
+	t := &task.Task{}
+	err := c.do(http.MethodGet, "/tasks/"+id.String(), nil, t)
+	return t, err
+}
+
+func (c *Client) RunTask(te task.TaskEvent) (*task.Task, error) {
+	t := &task.Task{}
+	err := c.do(http.MethodPost, "/tasks", te, t)
+	return t, err
+}
+
+func (c *Client) StopTask(id uuid.UUID) error {
+	return c.do(http.MethodDelete, "/tasks/"+id.String(), nil, nil)
+}
+
+// Logs streams the logs of the task with id. The caller must close the
+// returned reader.
+func (c *Client) Logs(id uuid.UUID, query url.Values) (io.ReadCloser, error) {
+	resp, err := c.request(http.MethodGet, "/tasks/"+id.String()+"/logs?"+query.Encode(), "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkStatus(resp)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+// Events returns the task events query selects, oldest first.
+func (c *Client) Events(query url.Values) ([]*task.Event, error) {
+	var events []*task.Event
+	err := c.do(http.MethodGet, "/events?"+query.Encode(), nil, &events)
+	return events, err
+}
+
+func (c *Client) Nodes() ([]*node.Node, error) {
+	var nodes []*node.Node
+	err := c.do(http.MethodGet, "/nodes", nil, &nodes)
+	return nodes, err
+}
+
+// SetNodeScheduling posts action, one of cordon, uncordon or drain, to the
+// named node.
+func (c *Client) SetNodeScheduling(name, action string) (*node.Node, error) {
+	n := &node.Node{}
+	err := c.do(http.MethodPost, "/nodes/"+url.PathEscape(name)+"/"+action, nil, n)
+	return n, err
+}
+
+func (c *Client) Deployments() ([]*deployment.Deployment, error) {
+	var ds []*deployment.Deployment
+	err := c.do(http.MethodGet, "/deployments", nil, &ds)
+	return ds, err
+}
+
+func (c *Client) Deployment(name string) (*deployment.Deployment, error) {
+	d := &deployment.Deployment{}
+	err := c.do(http.MethodGet, "/deployments/"+url.PathEscape(name), nil, d)
+	return d, err
+}
+
+func (c *Client) Jobs() ([]*job.Job, error) {
+	var jobs []*job.Job
+	err := c.do(http.MethodGet, "/jobs", nil, &jobs)
+	return jobs, err
+}
+
+func (c *Client) Job(name string) (*job.Job, error) {
+	j := &job.Job{}
+	err := c.do(http.MethodGet, "/jobs/"+url.PathEscape(name), nil, j)
+	return j, err
+}
+
+func (c *Client) CronJobs() ([]*cronjob.CronJob, error) {
+	var cs []*cronjob.CronJob
+	err := c.do(http.MethodGet, "/cronjobs", nil, &cs)
+	return cs, err
+}
+
+func (c *Client) CronJob(name string) (*cronjob.CronJob, error) {
+	cj := &cronjob.CronJob{}
+	err := c.do(http.MethodGet, "/cronjobs/"+url.PathEscape(name), nil, cj)
+	return cj, err
+}
+
+// Apply posts manifests to the manager. Results are returned even when some
+// manifests failed to apply.
+func (c *Client) Apply(manifests []byte, dryRun bool) ([]manager.ApplyResult, error) {
+	path := "/apply"
+	if dryRun {
+		path += "?dryRun=true"
+	}
+
+	resp, err := c.request(http.MethodPost, path, "application/yaml", bytes.NewReader(manifests))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		err = checkStatus(resp)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var results []manager.ApplyResult
+	err = json.NewDecoder(resp.Body).Decode(&results)
+	return results, err
+}
+
+// do sends in as JSON, unless it is nil, and decodes the response into out,
+// unless it is nil.
+func (c *Client) do(method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	resp, err := c.request(method, path, "application/json", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	err = checkStatus(resp)
+	if err != nil || out == nil {
+		return err
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (c *Client) request(method, path, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.Address+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot reach manager at %s: %v", c.Address, err)
+	}
+
+	return resp, nil
+}
+
+// checkStatus turns an error response into an error, using the message of
+// the manager's ErrResponse when there is one.
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+
+	e := common.ErrResponse{}
+	data, _ := io.ReadAll(resp.Body)
+	if json.Unmarshal(data, &e) == nil && e.Message != "" {
+		return fmt.Errorf("%s", strings.TrimSpace(e.Message))
+	}
+
+	return fmt.Errorf("manager returned %s", resp.Status)
+}
@@ -0,0 +1,772 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codding-buddha/mini-kube/cronjob"
+	"github.com/codding-buddha/mini-kube/deployment"
+	"github.com/codding-buddha/mini-kube/job"
+	"github.com/codding-buddha/mini-kube/manager"
+	"github.com/codding-buddha/mini-kube/node"
+	"github.com/codding-buddha/mini-kube/task"
+	"github.com/docker/go-connections/nat"
+	"github.com/google/uuid"
+)
+
+// listFlag collects every value of a repeated flag.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// parseInterleaved parses fs from args, allowing flags after positional
+// arguments as in "get tasks -o json", and returns the positional ones.
+func parseInterleaved(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func runCmd(c *Client, args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	name := fs.String("name", "", "task name")
+	memory := fs.Int64("memory", 0, "memory limit in bytes")
+	disk := fs.Int64("disk", 0, "disk needed in bytes")
+	cpu := fs.Float64("cpu", 0, "CPUs to reserve")
+	healthCheck := fs.String("health-check", "", "HTTP path the manager checks the task on")
+	restart := fs.String("restart", "", "restart policy: Always, OnFailure or Never")
+	var env, ports, publish listFlag
+	fs.Var(&env, "env", "KEY=VALUE environment variable, may be repeated")
+	fs.Var(&ports, "port", "container port to expose such as 7777/tcp, may be repeated")
+	fs.Var(&publish, "publish", "HOST:CONTAINER port to publish such as 8080:7777/tcp, may be repeated")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: cube run [flags] IMAGE [CMD...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	t := task.Task{
+		ID:            uuid.New(),
+		Name:          *name,
+		State:         task.Scheduled,
+		Image:         fs.Arg(0),
+		Cmd:           fs.Args()[1:],
+		Env:           env,
+		Memory:        *memory,
+		Disk:          *disk,
+		Cpu:           *cpu,
+		HealthCheck:   *healthCheck,
+		RestartPolicy: *restart,
+	}
+	if t.Name == "" {
+		t.Name = fmt.Sprintf("task-%s", t.ID.String()[:8])
+	}
+
+	for _, p := range ports {
+		addPort(&t, containerPort(p))
+	}
+
+	for _, p := range publish {
+		parts := strings.SplitN(p, ":", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid -publish %q, want HOST:CONTAINER", p)
+		}
+
+		port := containerPort(parts[1])
+		addPort(&t, port)
+		if t.PortBindings == nil {
+			t.PortBindings = make(map[string]string)
+		}
+		t.PortBindings[port] = parts[0]
+	}
+
+	_, err := c.RunTask(task.TaskEvent{
+		ID:        uuid.New(),
+		State:     task.Scheduled,
+		Timestamp: time.Now(),
+		Task:      t,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(t.ID)
+	return nil
+}
+
+// containerPort adds the tcp protocol to p when it has none.
+func containerPort(p string) string {
+	if !strings.Contains(p, "/") {
+		return p + "/tcp"
+	}
+
+	return p
+}
+
+func addPort(t *task.Task, port string) {
+	if t.ExposedPorts == nil {
+		t.ExposedPorts = nat.PortSet{}
+	}
+	t.ExposedPorts[nat.Port(port)] = struct{}{}
+}
+
+func stopCmd(c *Client, args []string) error {
+	fs := flag.NewFlagSet("stop", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: cube stop TASK...")
+	}
+	args = parseInterleaved(fs, args)
+	if len(args) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	for _, arg := range args {
+		t, err := findTask(c, arg)
+		if err != nil {
+			return err
+		}
+
+		err = c.StopTask(t.ID)
+		if err != nil {
+			return fmt.Errorf("stopping task %v: %v", t.ID, err)
+		}
+		fmt.Printf("Stopping task %v\n", t.ID)
+	}
+
+	return nil
+}
+
+// findTask looks a task up by its ID, a unique prefix of its ID or its name.
+// When several tasks share a name, the most recent active one wins.
+func findTask(c *Client, ref string) (*task.Task, error) {
+	if id, err := uuid.Parse(ref); err == nil {
+		return c.Task(id)
+	}
+
+	tasks, err := c.Tasks()
+	if err != nil {
+		return nil, err
+	}
+
+	var byPrefix, byName []*task.Task
+	for _, t := range tasks {
+		if strings.HasPrefix(t.ID.String(), ref) {
+			byPrefix = append(byPrefix, t)
+		}
+		if t.Name == ref {
+			byName = append(byName, t)
+		}
+	}
+
+	switch {
+	case len(byPrefix) == 1:
+		return byPrefix[0], nil
+	case len(byPrefix) > 1:
+		return nil, fmt.Errorf("task ID prefix %q is ambiguous", ref)
+	case len(byName) == 0:
+		return nil, fmt.Errorf("no task %q found", ref)
+	}
+
+	best := byName[0]
+	for _, t := range byName[1:] {
+		if active(t) != active(best) {
+			if active(t) {
+				best = t
+			}
+			continue
+		}
+		if t.StartTime.After(best.StartTime) {
+			best = t
+		}
+	}
+
+	return best, nil
+}
+
+func active(t *task.Task) bool {
+	switch t.State {
+	case task.Pending, task.Scheduled, task.Running, task.Restarting, task.Unknown:
+		return true
+	default:
+		return false
+	}
+}
+
+func getCmd(c *Client, args []string) error {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	format := fs.String("o", tableFormat, "output format: table, json or yaml")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: cube get tasks|nodes|deployments|jobs|cronjobs [NAME] [-o format]")
+	}
+	args = parseInterleaved(fs, args)
+	if len(args) == 0 || len(args) > 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	var name string
+	if len(args) == 2 {
+		name = args[1]
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "task", "tasks":
+		return getTasks(c, name, *format)
+	case "node", "nodes":
+		return getNodes(c, name, *format)
+	case "deployment", "deployments":
+		return getDeployments(c, name, *format)
+	case "job", "jobs":
+		return getJobs(c, name, *format)
+	case "cronjob", "cronjobs":
+		return getCronJobs(c, name, *format)
+	default:
+		return fmt.Errorf("unknown resource %q", args[0])
+	}
+}
+
+func nodesCmd(c *Client, args []string) error {
+	fs := flag.NewFlagSet("nodes", flag.ExitOnError)
+	format := fs.String("o", tableFormat, "output format: table, json or yaml")
+	fs.Parse(args)
+	return getNodes(c, "", *format)
+}
+
+func getTasks(c *Client, name, format string) error {
+	var v interface{}
+	var tasks []*task.Task
+	if name != "" {
+		t, err := findTask(c, name)
+		if err != nil {
+			return err
+		}
+		tasks, v = []*task.Task{t}, t
+	} else {
+		var err error
+		tasks, err = c.Tasks()
+		if err != nil {
+			return err
+		}
+		v = tasks
+	}
+
+	return output(format, v, func(w io.Writer) {
+		row(w, "ID", "NAME", "STATE", "READY", "IMAGE", "OWNER", "RESTARTS", "AGE")
+		for _, t := range tasks {
+			row(w, t.ID, t.Name, orDash(t.Status), t.Ready, t.Image, orDash(t.Owner), t.RestartCount, age(t.StartTime))
+		}
+	})
+}
+
+func getNodes(c *Client, name, format string) error {
+	nodes, err := c.Nodes()
+	if err != nil {
+		return err
+	}
+
+	if name != "" {
+		var found []*node.Node
+		for _, n := range nodes {
+			if n.Name == name {
+				found = append(found, n)
+			}
+		}
+		if len(found) == 0 {
+			return fmt.Errorf("no node %q found", name)
+		}
+		nodes = found
+	}
+
+	return output(format, nodes, func(w io.Writer) {
+		row(w, "NAME", "API", "STATE", "TASKS", "MEMORY", "DISK", "LAST HEARTBEAT")
+		for _, n := range nodes {
+			row(w, n.Name, n.Api, nodeState(n), n.TaskCount,
+				bytesSize(n.MemoryAllocated)+"/"+bytesSize(n.Memory),
+				bytesSize(n.DiskAllocated)+"/"+bytesSize(n.Disk),
+				age(n.LastHeartbeat))
+		}
+	})
+}
+
+// nodeState describes n's health and whether it takes new tasks.
+func nodeState(n *node.Node) string {
+	s := n.State.String()
+	switch {
+	case n.Draining:
+		s += ",Draining"
+	case n.Unschedulable:
+		s += ",Cordoned"
+	}
+
+	return s
+}
+
+func cordonCmd(c *Client, args []string) error {
+	return setScheduling(c, "cordon", "cordoned", args)
+}
+
+func uncordonCmd(c *Client, args []string) error {
+	return setScheduling(c, "uncordon", "uncordoned", args)
+}
+
+func setScheduling(c *Client, action, done string, args []string) error {
+	fs := flag.NewFlagSet(action, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: cube %s NODE...\n", action)
+	}
+	args = parseInterleaved(fs, args)
+	if len(args) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	for _, name := range args {
+		_, err := c.SetNodeScheduling(name, action)
+		if err != nil {
+			return fmt.Errorf("node %s: %v", name, err)
+		}
+		fmt.Printf("Node %s %s\n", name, done)
+	}
+
+	return nil
+}
+
+func drainCmd(c *Client, args []string) error {
+	fs := flag.NewFlagSet("drain", flag.ExitOnError)
+	wait := fs.Bool("wait", false, "wait until the node has no tasks left")
+	timeout := fs.Duration("timeout", 5*time.Minute, "how long -wait waits")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: cube drain [-wait] [-timeout D] NODE")
+		fs.PrintDefaults()
+	}
+	args = parseInterleaved(fs, args)
+	if len(args) != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	name := args[0]
+	n, err := c.SetNodeScheduling(name, "drain")
+	if err != nil {
+		return fmt.Errorf("node %s: %v", name, err)
+	}
+	fmt.Printf("Node %s draining, %d tasks to move\n", name, n.TaskCount)
+	if !*wait {
+		return nil
+	}
+
+	deadline := time.Now().Add(*timeout)
+	for n.TaskCount > 0 {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("node %s still has %d tasks after %v", name, n.TaskCount, *timeout)
+		}
+
+		time.Sleep(2 * time.Second)
+		nodes, err := c.Nodes()
+		if err != nil {
+			return err
+		}
+
+		n = nil
+		for _, candidate := range nodes {
+			if candidate.Name == name {
+				n = candidate
+			}
+		}
+		if n == nil {
+			return fmt.Errorf("node %s disappeared", name)
+		}
+		if !n.Draining {
+			return fmt.Errorf("node %s is no longer draining", name)
+		}
+	}
+
+	fmt.Printf("Node %s drained\n", name)
+	return nil
+}
+
+func getDeployments(c *Client, name, format string) error {
+	// A named resource is printed on its own rather than as a list.
+	var v interface{}
+	var ds []*deployment.Deployment
+	var err error
+	if name != "" {
+		var d *deployment.Deployment
+		d, err = c.Deployment(name)
+		ds, v = []*deployment.Deployment{d}, d
+	} else {
+		ds, err = c.Deployments()
+		v = ds
+	}
+	if err != nil {
+		return err
+	}
+
+	return output(format, v, func(w io.Writer) {
+		row(w, "NAME", "READY", "UP-TO-DATE", "RUNNING", "REVISION", "PAUSED")
+		for _, d := range ds {
+			row(w, d.Name, fmt.Sprintf("%d/%d", d.Status.AvailableReplicas, d.Replicas),
+				d.Status.UpdatedReplicas, d.Status.RunningReplicas, d.Revision, d.Paused)
+		}
+	})
+}
+
+func getJobs(c *Client, name, format string) error {
+	var v interface{}
+	var jobs []*job.Job
+	var err error
+	if name != "" {
+		var j *job.Job
+		j, err = c.Job(name)
+		jobs, v = []*job.Job{j}, j
+	} else {
+		jobs, err = c.Jobs()
+		v = jobs
+	}
+	if err != nil {
+		return err
+	}
+
+	return output(format, v, func(w io.Writer) {
+		row(w, "NAME", "CONDITION", "COMPLETIONS", "ACTIVE", "FAILED", "AGE")
+		for _, j := range jobs {
+			row(w, j.Name, orDash(j.Status.Condition), fmt.Sprintf("%d/%d", j.Status.Succeeded, j.Completions),
+				j.Status.Active, j.Status.Failed, age(j.Status.StartTime))
+		}
+	})
+}
+
+func getCronJobs(c *Client, name, format string) error {
+	var v interface{}
+	var cs []*cronjob.CronJob
+	var err error
+	if name != "" {
+		var cj *cronjob.CronJob
+		cj, err = c.CronJob(name)
+		cs, v = []*cronjob.CronJob{cj}, cj
+	} else {
+		cs, err = c.CronJobs()
+		v = cs
+	}
+	if err != nil {
+		return err
+	}
+
+	return output(format, v, func(w io.Writer) {
+		row(w, "NAME", "SCHEDULE", "POLICY", "ACTIVE", "LAST SCHEDULE", "AGE")
+		for _, cj := range cs {
+			row(w, cj.Name, cj.Schedule, cj.ConcurrencyPolicy, len(cj.Status.Active),
+				age(cj.Status.LastScheduleTime), age(cj.Status.CreatedAt))
+		}
+	})
+}
+
+func describeCmd(c *Client, args []string) error {
+	fs := flag.NewFlagSet("describe", flag.ExitOnError)
+	format := fs.String("o", tableFormat, "output format: table, json or yaml")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: cube describe task TASK [-o format]")
+	}
+	args = parseInterleaved(fs, args)
+	if len(args) != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	if kind := strings.ToLower(args[0]); kind != "task" && kind != "tasks" {
+		return fmt.Errorf("cannot describe %q, only tasks", args[0])
+	}
+
+	t, err := findTask(c, args[1])
+	if err != nil {
+		return err
+	}
+
+	return output(*format, t, func(w io.Writer) {
+		row(w, "ID:", t.ID)
+		row(w, "Name:", t.Name)
+		row(w, "State:", orDash(t.Status))
+		row(w, "Reason:", orDash(t.Reason))
+		if t.Message != "" {
+			row(w, "Message:", t.Message)
+		}
+		row(w, "Ready:", t.Ready)
+		row(w, "Image:", t.Image)
+		row(w, "Command:", orDash(strings.Join(t.Cmd, " ")))
+		row(w, "Owner:", orDash(t.Owner))
+		if t.Owner != "" {
+			row(w, "Revision:", t.Revision)
+		}
+		row(w, "Container:", orDash(t.ContainerID))
+		row(w, "IP:", orDash(t.IP))
+		row(w, "Resources:", fmt.Sprintf("cpu=%g memory=%s disk=%s", t.Cpu, bytesSize(t.Memory), bytesSize(t.Disk)))
+		for _, e := range t.Env {
+			row(w, "Env:", e)
+		}
+		for port, bindings := range t.HostPorts {
+			for _, b := range bindings {
+				row(w, "Port:", fmt.Sprintf("%s -> %s:%s", port, b.HostIP, b.HostPort))
+			}
+		}
+		row(w, "Health check:", orDash(t.HealthCheck))
+		row(w, "Liveness:", describeProbe(t.LivenessProbe))
+		row(w, "Readiness:", describeProbe(t.ReadinessProbe))
+		row(w, "Startup:", describeProbe(t.StartupProbe))
+		if t.Health.Unhealthy {
+			row(w, "Health:", fmt.Sprintf("Unhealthy (%s)", t.Health.Reason))
+		}
+		row(w, "Restart policy:", t.Restart())
+		row(w, "Restarts:", t.RestartCount)
+		for _, r := range t.Restarts {
+			row(w, "Restarted:", fmt.Sprintf("%s (%s)", timestamp(r.Time), r.Reason))
+		}
+		if t.Status == task.CrashLoopBackOff {
+			row(w, "Next restart:", fmt.Sprintf("in %v", time.Until(t.RestartAt).Round(time.Second)))
+		}
+		row(w, "Started:", timestamp(t.StartTime))
+		row(w, "Finished:", timestamp(t.FinishTime))
+		if t.State == task.Completed || t.State == task.Failed {
+			row(w, "Exit code:", t.ExitCode)
+		}
+	})
+}
+
+// describeProbe summarises a probe on one line, such as
+// "http-get :8080/healthz delay=0s period=10s timeout=1s success=1 failure=3".
+func describeProbe(p *task.Probe) string {
+	if p == nil {
+		return "-"
+	}
+
+	var action string
+	switch {
+	case p.HTTPGet != nil:
+		action = fmt.Sprintf("http-get :%s%s", probePort(p.HTTPGet.Port), p.HTTPGet.Path)
+	case p.TCPSocket != nil:
+		action = fmt.Sprintf("tcp-socket :%s", probePort(p.TCPSocket.Port))
+	case p.Exec != nil:
+		action = fmt.Sprintf("exec [%s]", strings.Join(p.Exec.Command, " "))
+	}
+
+	success, failure := p.Thresholds()
+	return fmt.Sprintf("%s delay=%v period=%v timeout=%v success=%d failure=%d",
+		action, p.InitialDelay(), p.Period(), p.Timeout(), success, failure)
+}
+
+func probePort(port int) string {
+	if port == 0 {
+		return "<first>"
+	}
+
+	return strconv.Itoa(port)
+}
+
+func timestamp(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return fmt.Sprintf("%s (%s ago)", t.Local().Format(time.RFC3339), age(t))
+}
+
+func logsCmd(c *Client, args []string) error {
+	fs := flag.NewFlagSet("logs", flag.ExitOnError)
+	follow := fs.Bool("f", false, "keep streaming new output")
+	tail := fs.String("tail", "", "number of lines to show from the end, or all")
+	since := fs.String("since", "", "only show logs since an RFC 3339 time or a duration such as 10m")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: cube logs [-f] [-tail N] [-since T] TASK")
+		fs.PrintDefaults()
+	}
+	args = parseInterleaved(fs, args)
+	if len(args) != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	t, err := findTask(c, args[0])
+	if err != nil {
+		return err
+	}
+
+	q := url.Values{}
+	if *follow {
+		q.Set("follow", "true")
+	}
+	if *tail != "" {
+		q.Set("tail", *tail)
+	}
+	if *since != "" {
+		q.Set("since", *since)
+	}
+
+	logs, err := c.Logs(t.ID, q)
+	if err != nil {
+		return err
+	}
+	defer logs.Close()
+
+	_, err = io.Copy(os.Stdout, logs)
+	return err
+}
+
+func eventsCmd(c *Client, args []string) error {
+	fs := flag.NewFlagSet("events", flag.ExitOnError)
+	nodeName := fs.String("node", "", "only show events on this node")
+	state := fs.String("state", "", "only show events of tasks entering this state")
+	reason := fs.String("reason", "", "only show events with this reason")
+	since := fs.String("since", "", "only show events since an RFC 3339 time or a duration such as 10m")
+	limit := fs.Int("limit", 0, "only show this many of the most recent events")
+	format := fs.String("o", tableFormat, "output format: table, json or yaml")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: cube events [flags] [TASK]")
+		fs.PrintDefaults()
+	}
+	args = parseInterleaved(fs, args)
+	if len(args) > 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	q := url.Values{}
+	if len(args) == 1 {
+		t, err := findTask(c, args[0])
+		if err != nil {
+			return err
+		}
+		q.Set("task", t.ID.String())
+	}
+	if *nodeName != "" {
+		q.Set("node", *nodeName)
+	}
+	if *state != "" {
+		q.Set("state", *state)
+	}
+	if *reason != "" {
+		q.Set("reason", *reason)
+	}
+	if *since != "" {
+		q.Set("since", *since)
+	}
+	if *limit > 0 {
+		q.Set("limit", strconv.Itoa(*limit))
+	}
+
+	events, err := c.Events(q)
+	if err != nil {
+		return err
+	}
+
+	return output(*format, events, func(w io.Writer) {
+		row(w, "AGE", "TASK", "STATE", "REASON", "NODE", "MESSAGE")
+		for _, e := range events {
+			row(w, age(e.Timestamp), e.TaskName, e.State, e.Reason, orDash(e.Node), orDash(e.Message))
+		}
+	})
+}
+
+func applyCmd(c *Client, args []string) error {
+	fs := flag.NewFlagSet("apply", flag.ExitOnError)
+	var files listFlag
+	fs.Var(&files, "f", "manifest file to apply, or - for stdin; may be repeated")
+	dryRun := fs.Bool("dry-run", false, "only show what would change")
+	format := fs.String("o", tableFormat, "output format: table, json or yaml")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: cube apply -f FILE [-dry-run] [-o format]")
+		fs.PrintDefaults()
+	}
+	parseInterleaved(fs, args)
+	if len(files) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	var results []manager.ApplyResult
+	for _, f := range files {
+		data, err := readFile(f)
+		if err != nil {
+			return err
+		}
+
+		rs, err := c.Apply(data, *dryRun)
+		if err != nil {
+			return fmt.Errorf("%s: %v", f, err)
+		}
+		results = append(results, rs...)
+	}
+
+	suffix := ""
+	if *dryRun {
+		suffix = " (dry run)"
+	}
+
+	err := output(*format, results, func(w io.Writer) {
+		for _, r := range results {
+			ref := strings.ToLower(r.Kind) + "/" + r.Name
+			if r.Error != "" {
+				row(w, ref, "error: "+r.Error)
+				continue
+			}
+			row(w, ref, r.Action+suffix)
+			if r.Action == manager.Configured {
+				for _, d := range r.Diff {
+					row(w, "  "+d)
+				}
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.Error != "" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d manifests failed to apply", failed, len(results))
+	}
+
+	return nil
+}
+
+func readFile(name string) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+
+	data, err := os.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("manifest %s not found", name)
+	}
+
+	return data, err
+}
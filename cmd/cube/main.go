@@ -0,0 +1,84 @@
+// Command cube is a command-line client for the mini-kube manager API.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+const usage = `Usage: cube [-manager HOST:PORT] COMMAND [ARGS]
+
+Commands:
+  run [flags] IMAGE [CMD...]       start a task
+  stop TASK...                     stop tasks
+  get RESOURCE [NAME]              list tasks, nodes, deployments, jobs or cronjobs
+  describe task TASK               show a task in detail
+  logs [-f] [-tail N] TASK         print the logs of a task
+  events [TASK]                    list what happened to tasks
+  nodes                            list worker nodes
+  cordon NODE...                   stop placing new tasks on nodes
+  uncordon NODE...                 let nodes receive new tasks again
+  drain [-wait] NODE               cordon a node and move its tasks elsewhere
+  apply -f FILE [-dry-run]         create or update resources from manifests
+
+TASK is a task ID, a unique prefix of one, or a task name. get, describe,
+events, nodes and apply take -o table, json or yaml. Run "cube COMMAND -h" for the
+flags of a command.
+
+The manager address defaults to $MINI_KUBE_MANAGER_HOST:$MINI_KUBE_MANAGER_PORT,
+or localhost:5555 when they are not set.
+`
+
+func main() {
+	fs := flag.NewFlagSet("cube", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+	}
+	address := fs.String("manager", defaultManager(), "address of the manager API")
+	fs.Parse(os.Args[1:])
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	commands := map[string]func(*Client, []string) error{
+		"run":      runCmd,
+		"stop":     stopCmd,
+		"get":      getCmd,
+		"describe": describeCmd,
+		"logs":     logsCmd,
+		"events":   eventsCmd,
+		"nodes":    nodesCmd,
+		"cordon":   cordonCmd,
+		"uncordon": uncordonCmd,
+		"drain":    drainCmd,
+		"apply":    applyCmd,
+	}
+
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "cube: unknown command %q\n\n", fs.Arg(0))
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	err := cmd(NewClient(*address), fs.Args()[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cube: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func defaultManager() string {
+	host := os.Getenv("MINI_KUBE_MANAGER_HOST")
+	port := os.Getenv("MINI_KUBE_MANAGER_PORT")
+	if host == "" {
+		host = "localhost"
+	}
+	if port == "" {
+		port = "5555"
+	}
+
+	return fmt.Sprintf("%s:%s", host, port)
+}
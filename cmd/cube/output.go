@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats chosen with -o.
+const (
+	tableFormat = "table"
+	jsonFormat  = "json"
+	yamlFormat  = "yaml"
+)
+
+// output writes v to stdout as JSON or YAML, or calls table to write it as
+// aligned columns.
+func output(format string, v interface{}, table func(w io.Writer)) error {
+	switch format {
+	case jsonFormat:
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	case yamlFormat:
+		// Going through JSON keeps field names the same in both formats.
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+
+		var doc interface{}
+		err = json.Unmarshal(data, &doc)
+		if err != nil {
+			return err
+		}
+
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		err = enc.Encode(doc)
+		if err != nil {
+			return err
+		}
+		return enc.Close()
+	case tableFormat, "":
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 3, ' ', 0)
+		table(w)
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown output format %q, want table, json or yaml", format)
+	}
+
+	return nil
+}
+
+func row(w io.Writer, cols ...interface{}) {
+	s := make([]string, len(cols))
+	for i, c := range cols {
+		s[i] = fmt.Sprint(c)
+	}
+	fmt.Fprintln(w, strings.Join(s, "\t"))
+}
+
+// age formats how long ago t was, such as "5m" or "3d".
+func age(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
+
+// orDash returns s, or "-" when s is empty.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
+
+// bytesSize formats n bytes in the largest unit that keeps it above one.
+func bytesSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,33 @@
+package common
+
+import (
+	"io"
+	"net/http"
+)
+
+// CopyAndFlush copies src to w, flushing after every chunk so streamed
+// responses such as followed logs reach the client as they are produced.
+func CopyAndFlush(w http.ResponseWriter, src io.Reader) error {
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return werr
+			}
+
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
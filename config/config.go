@@ -0,0 +1,120 @@
+// Package config holds the settings of the manager and worker commands.
+//
+// Settings come from, in increasing order of priority: built-in defaults, a
+// YAML or JSON file named by -config, MINI_KUBE_* environment variables and
+// command-line flags.
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is implemented by Manager and Worker.
+type Config interface {
+	// RegisterFlags binds the settings to flags of fs.
+	RegisterFlags(fs *flag.FlagSet)
+	// Validate reports settings that cannot work.
+	Validate() error
+	// env maps environment variables to the flags they set.
+	env() map[string]string
+}
+
+// Parse fills cfg from the config file, environment and flags in args.
+func Parse(name string, args []string, cfg Config) error {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	path := fs.String("config", "", "YAML or JSON file to read settings from")
+	cfg.RegisterFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	set := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = f.Value.String()
+	})
+
+	if *path != "" {
+		err := load(*path, cfg)
+		if err != nil {
+			return err
+		}
+	}
+
+	for env, name := range cfg.env() {
+		v := os.Getenv(env)
+		if _, explicit := set[name]; v == "" || explicit {
+			continue
+		}
+
+		err := fs.Set(name, v)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %v", env, err)
+		}
+	}
+
+	// The file overwrote everything it mentions, so flags given on the
+	// command line are applied again to take precedence.
+	for name, v := range set {
+		fs.Set(name, v)
+	}
+
+	return cfg.Validate()
+}
+
+func load(path string, cfg Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("unable to open config file: %v", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	err = dec.Decode(cfg)
+	if err != nil {
+		return fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+
+	return nil
+}
+
+// listFlag is a comma-separated list. Setting it replaces the whole list.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(v string) error {
+	*l = nil
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			*l = append(*l, s)
+		}
+	}
+
+	return nil
+}
+
+func validatePort(port int) error {
+	if port <= 0 || port > 65535 {
+		return fmt.Errorf("port %d is out of range", port)
+	}
+
+	return nil
+}
+
+func validateStore(s string) error {
+	switch s {
+	case "memory", "persistent":
+		return nil
+	default:
+		return fmt.Errorf("unknown store %q, want memory or persistent", s)
+	}
+}
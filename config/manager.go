@@ -0,0 +1,149 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+)
+
+// Manager configures a manager process.
+type Manager struct {
+	// Host and Port are where the manager API listens.
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"`
+	// Workers are host:port addresses of workers known before they register.
+	Workers   []string `yaml:"workers"`
+	Scheduler string   `yaml:"scheduler"`
+	// Store is "memory" or "persistent". Persistent stores are kept in
+	// DataDir.
+	Store   string `yaml:"store"`
+	DataDir string `yaml:"dataDir"`
+
+	ProcessInterval       time.Duration `yaml:"processInterval"`
+	UpdateInterval        time.Duration `yaml:"updateInterval"`
+	StatsInterval         time.Duration `yaml:"statsInterval"`
+	HealthCheckInterval   time.Duration `yaml:"healthCheckInterval"`
+	NodeCheckInterval     time.Duration `yaml:"nodeCheckInterval"`
+	ReconcileInterval     time.Duration `yaml:"reconcileInterval"`
+	NotReadyTimeout       time.Duration `yaml:"notReadyTimeout"`
+	LostTimeout           time.Duration `yaml:"lostTimeout"`
+	MaxConcurrentDispatch int           `yaml:"maxConcurrentDispatch"`
+	// MaxRestarts caps how often a task is restarted, 0 meaning no cap.
+	// Repeated restarts back off from RestartBackoff up to
+	// MaxRestartBackoff.
+	MaxRestarts       int           `yaml:"maxRestarts"`
+	RestartBackoff    time.Duration `yaml:"restartBackoff"`
+	MaxRestartBackoff time.Duration `yaml:"maxRestartBackoff"`
+	// MaxTaskEvents is how many events are kept for each task, 0 meaning
+	// no limit.
+	MaxTaskEvents int `yaml:"maxTaskEvents"`
+	// ShutdownTimeout bounds how long the manager waits for requests and
+	// work in progress when it is asked to stop.
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+}
+
+// DefaultManager returns the settings a manager runs with when nothing else
+// is configured.
+func DefaultManager() *Manager {
+	return &Manager{
+		Host:                  "0.0.0.0",
+		Port:                  5555,
+		Scheduler:             "roundrobin",
+		Store:                 "memory",
+		ProcessInterval:       10 * time.Second,
+		UpdateInterval:        15 * time.Second,
+		StatsInterval:         15 * time.Second,
+		HealthCheckInterval:   5 * time.Second,
+		NodeCheckInterval:     10 * time.Second,
+		ReconcileInterval:     10 * time.Second,
+		NotReadyTimeout:       30 * time.Second,
+		LostTimeout:           90 * time.Second,
+		MaxConcurrentDispatch: 4,
+		MaxRestarts:           3,
+		RestartBackoff:        10 * time.Second,
+		MaxRestartBackoff:     5 * time.Minute,
+		MaxTaskEvents:         100,
+		ShutdownTimeout:       30 * time.Second,
+	}
+}
+
+func (c *Manager) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Host, "host", c.Host, "address the API listens on")
+	fs.IntVar(&c.Port, "port", c.Port, "port the API listens on")
+	fs.Var((*listFlag)(&c.Workers), "workers", "comma-separated host:port addresses of workers")
+	fs.StringVar(&c.Scheduler, "scheduler", c.Scheduler, "scheduler: roundrobin, greedy or epvm")
+	fs.StringVar(&c.Store, "store", c.Store, "store: memory or persistent")
+	fs.StringVar(&c.DataDir, "data-dir", c.DataDir, "directory of persistent stores")
+	fs.DurationVar(&c.ProcessInterval, "process-interval", c.ProcessInterval, "how often the pending queue is checked")
+	fs.DurationVar(&c.UpdateInterval, "update-interval", c.UpdateInterval, "how often task states are fetched from workers")
+	fs.DurationVar(&c.StatsInterval, "stats-interval", c.StatsInterval, "how often node stats are fetched from workers")
+	fs.DurationVar(&c.HealthCheckInterval, "health-check-interval", c.HealthCheckInterval, "how often tasks are checked for restarts")
+	fs.DurationVar(&c.NodeCheckInterval, "node-check-interval", c.NodeCheckInterval, "how often node heartbeats are checked")
+	fs.DurationVar(&c.ReconcileInterval, "reconcile-interval", c.ReconcileInterval, "how often deployments, jobs and cron jobs are reconciled")
+	fs.DurationVar(&c.NotReadyTimeout, "not-ready-timeout", c.NotReadyTimeout, "missed heartbeats after which a node gets no new tasks")
+	fs.DurationVar(&c.LostTimeout, "lost-timeout", c.LostTimeout, "missed heartbeats after which a node's tasks are rescheduled")
+	fs.IntVar(&c.MaxConcurrentDispatch, "max-concurrent-dispatch", c.MaxConcurrentDispatch, "tasks posted to workers at once")
+	fs.IntVar(&c.MaxRestarts, "max-restarts", c.MaxRestarts, "how often a task is restarted, 0 for no limit")
+	fs.DurationVar(&c.RestartBackoff, "restart-backoff", c.RestartBackoff, "wait before the second restart of a task, doubled for each one after")
+	fs.DurationVar(&c.MaxRestartBackoff, "max-restart-backoff", c.MaxRestartBackoff, "longest wait between restarts of a task")
+	fs.IntVar(&c.MaxTaskEvents, "max-task-events", c.MaxTaskEvents, "events kept for each task, 0 for no limit")
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", c.ShutdownTimeout, "how long to wait for work in progress when stopping")
+}
+
+func (c *Manager) Validate() error {
+	err := validatePort(c.Port)
+	if err != nil {
+		return err
+	}
+
+	err = validateStore(c.Store)
+	if err != nil {
+		return err
+	}
+
+	switch c.Scheduler {
+	case "roundrobin", "greedy", "epvm":
+	default:
+		return fmt.Errorf("unknown scheduler %q, want roundrobin, greedy or epvm", c.Scheduler)
+	}
+
+	for _, d := range []time.Duration{c.ProcessInterval, c.UpdateInterval, c.StatsInterval,
+		c.HealthCheckInterval, c.NodeCheckInterval, c.ReconcileInterval, c.ShutdownTimeout} {
+		if d <= 0 {
+			return errors.New("intervals and timeouts must be positive")
+		}
+	}
+
+	if c.NotReadyTimeout <= 0 || c.LostTimeout < c.NotReadyTimeout {
+		return errors.New("lost timeout must be at least the not ready timeout, which must be positive")
+	}
+
+	if c.MaxConcurrentDispatch < 1 {
+		return errors.New("max concurrent dispatch must be at least 1")
+	}
+
+	if c.MaxRestarts < 0 {
+		return errors.New("max restarts must not be negative")
+	}
+
+	if c.RestartBackoff <= 0 || c.MaxRestartBackoff < c.RestartBackoff {
+		return errors.New("max restart backoff must be at least the restart backoff, which must be positive")
+	}
+
+	if c.MaxTaskEvents < 0 {
+		return errors.New("max task events must not be negative")
+	}
+
+	return nil
+}
+
+func (c *Manager) env() map[string]string {
+	return map[string]string{
+		"MINI_KUBE_MANAGER_HOST":        "host",
+		"MINI_KUBE_MANAGER_PORT":        "port",
+		"MINI_KUBE_SCHEDULER":           "scheduler",
+		"MINI_KUBE_MANAGER_DB":          "store",
+		"MINI_KUBE_WORKER_GRACE_PERIOD": "lost-timeout",
+	}
+}
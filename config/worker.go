@@ -0,0 +1,142 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Worker configures a worker process.
+type Worker struct {
+	// Host and Port are where the worker API listens.
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"`
+	// Advertise is the host:port the manager uses to reach the worker. It
+	// defaults to Host, or the machine's hostname when Host is a wildcard,
+	// and Port.
+	Advertise string `yaml:"advertise"`
+	// Manager is the host:port of the manager to register with.
+	Manager string `yaml:"manager"`
+	// Runtime is "docker" or "process". Processes keep their state in
+	// ProcessDir.
+	Runtime    string `yaml:"runtime"`
+	ProcessDir string `yaml:"processDir"`
+	// Store is "memory" or "persistent". A persistent store is kept in
+	// DataDir.
+	Store   string `yaml:"store"`
+	DataDir string `yaml:"dataDir"`
+
+	RunInterval        time.Duration `yaml:"runInterval"`
+	UpdateInterval     time.Duration `yaml:"updateInterval"`
+	StatsInterval      time.Duration `yaml:"statsInterval"`
+	HeartbeatInterval  time.Duration `yaml:"heartbeatInterval"`
+	MaxConcurrentTasks int           `yaml:"maxConcurrentTasks"`
+	// ShutdownTimeout bounds how long the worker waits for tasks being
+	// started or stopped when it is asked to stop. With StopTasksOnExit it
+	// also stops its running tasks; otherwise they keep running. Tasks of
+	// the process runtime are always stopped, as they cannot outlive the
+	// worker.
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+	StopTasksOnExit bool          `yaml:"stopTasksOnExit"`
+}
+
+// DefaultWorker returns the settings a worker runs with when nothing else is
+// configured.
+func DefaultWorker() *Worker {
+	return &Worker{
+		Host:               "0.0.0.0",
+		Port:               5556,
+		Manager:            "localhost:5555",
+		Runtime:            "docker",
+		ProcessDir:         filepath.Join(os.TempDir(), "mini-kube-processes"),
+		Store:              "memory",
+		RunInterval:        10 * time.Second,
+		UpdateInterval:     15 * time.Second,
+		StatsInterval:      15 * time.Second,
+		HeartbeatInterval:  10 * time.Second,
+		MaxConcurrentTasks: 4,
+		ShutdownTimeout:    30 * time.Second,
+	}
+}
+
+// Address returns the advertised host:port of the worker.
+func (c *Worker) Address() string {
+	if c.Advertise != "" {
+		return c.Advertise
+	}
+
+	host := c.Host
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		if name, err := os.Hostname(); err == nil {
+			host = name
+		}
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(c.Port))
+}
+
+func (c *Worker) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Host, "host", c.Host, "address the API listens on")
+	fs.IntVar(&c.Port, "port", c.Port, "port the API listens on")
+	fs.StringVar(&c.Advertise, "advertise", c.Advertise, "host:port the manager reaches the worker on")
+	fs.StringVar(&c.Manager, "manager", c.Manager, "host:port of the manager")
+	fs.StringVar(&c.Runtime, "runtime", c.Runtime, "task runtime: docker or process")
+	fs.StringVar(&c.ProcessDir, "process-dir", c.ProcessDir, "directory of the process runtime")
+	fs.StringVar(&c.Store, "store", c.Store, "store: memory or persistent")
+	fs.StringVar(&c.DataDir, "data-dir", c.DataDir, "directory of the persistent store")
+	fs.DurationVar(&c.RunInterval, "run-interval", c.RunInterval, "how often the task queue is checked")
+	fs.DurationVar(&c.UpdateInterval, "update-interval", c.UpdateInterval, "how often task states are checked with the runtime")
+	fs.DurationVar(&c.StatsInterval, "stats-interval", c.StatsInterval, "how often machine stats are collected")
+	fs.DurationVar(&c.HeartbeatInterval, "heartbeat-interval", c.HeartbeatInterval, "how often heartbeats are sent to the manager")
+	fs.IntVar(&c.MaxConcurrentTasks, "max-concurrent-tasks", c.MaxConcurrentTasks, "tasks started or stopped at once")
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", c.ShutdownTimeout, "how long to wait for work in progress when stopping")
+	fs.BoolVar(&c.StopTasksOnExit, "stop-tasks-on-exit", c.StopTasksOnExit, "stop running tasks when the worker stops")
+}
+
+func (c *Worker) Validate() error {
+	err := validatePort(c.Port)
+	if err != nil {
+		return err
+	}
+
+	err = validateStore(c.Store)
+	if err != nil {
+		return err
+	}
+
+	switch c.Runtime {
+	case "docker", "process":
+	default:
+		return fmt.Errorf("unknown runtime %q, want docker or process", c.Runtime)
+	}
+
+	if c.Manager == "" {
+		return errors.New("manager address is required")
+	}
+
+	for _, d := range []time.Duration{c.RunInterval, c.UpdateInterval, c.StatsInterval, c.HeartbeatInterval, c.ShutdownTimeout} {
+		if d <= 0 {
+			return errors.New("intervals and timeouts must be positive")
+		}
+	}
+
+	if c.MaxConcurrentTasks < 1 {
+		return errors.New("max concurrent tasks must be at least 1")
+	}
+
+	return nil
+}
+
+func (c *Worker) env() map[string]string {
+	return map[string]string{
+		"MINI_KUBE_WORKER_HOST":    "host",
+		"MINI_KUBE_WORKER_PORT":    "port",
+		"MINI_KUBE_WORKER_RUNTIME": "runtime",
+		"MINI_KUBE_WORKER_DB":      "store",
+	}
+}
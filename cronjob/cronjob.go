@@ -0,0 +1,88 @@
+package cronjob
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/codding-buddha/mini-kube/task"
+	"github.com/google/uuid"
+)
+
+// Concurrency policies decide what happens when a run is due while the
+// previous one is still active.
+const (
+	// Allow starts the new run alongside the active ones.
+	Allow = "Allow"
+	// Forbid skips the new run.
+	Forbid = "Forbid"
+	// Replace stops the active runs and starts the new one.
+	Replace = "Replace"
+)
+
+// CronJob runs its Template task on a cron Schedule.
+type CronJob struct {
+	Name              string
+	Schedule          string
+	ConcurrencyPolicy string
+	// SuccessfulHistoryLimit and FailedHistoryLimit are how many finished
+	// tasks of each kind are kept. Older ones are removed, and a limit of 0
+	// keeps none. They are pointers so a limit left out can be told apart
+	// from 0.
+	SuccessfulHistoryLimit *int
+	FailedHistoryLimit     *int
+	Template               task.Task
+	Status                 Status
+}
+
+// Status is what the manager last observed for a cron job.
+type Status struct {
+	Active           []uuid.UUID
+	LastScheduleTime time.Time
+	CreatedAt        time.Time
+}
+
+// SetDefaults normalises the concurrency policy, defaulting to Allow, and
+// keeps three successful and one failed task when no limits are given.
+func (c *CronJob) SetDefaults() {
+	switch strings.ToLower(c.ConcurrencyPolicy) {
+	case "forbid":
+		c.ConcurrencyPolicy = Forbid
+	case "replace":
+		c.ConcurrencyPolicy = Replace
+	case "", "allow":
+		c.ConcurrencyPolicy = Allow
+	}
+
+	if c.SuccessfulHistoryLimit == nil {
+		c.SuccessfulHistoryLimit = limit(3)
+	}
+
+	if c.FailedHistoryLimit == nil {
+		c.FailedHistoryLimit = limit(1)
+	}
+}
+
+func limit(n int) *int {
+	return &n
+}
+
+// Owner is the value of task.Task.Owner for tasks created for c.
+func (c *CronJob) Owner() string {
+	return fmt.Sprintf("cronjob/%s", c.Name)
+}
+
+// NewTask creates a pending task from the cron job's template for the run
+// scheduled at t.
+func (c *CronJob) NewTask(t time.Time) task.Task {
+	nt := c.Template
+	nt.ID = uuid.New()
+	nt.Name = fmt.Sprintf("%s-%d", c.Name, t.Unix())
+	nt.Owner = c.Owner()
+	nt.State = task.Pending
+	nt.ContainerID = ""
+	nt.HostPorts = nil
+	nt.RestartCount = 0
+	nt.ExitCode = 0
+	return nt
+}
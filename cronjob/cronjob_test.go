@@ -0,0 +1,25 @@
+package cronjob
+
+import "testing"
+
+func TestSetDefaultsHistoryLimits(t *testing.T) {
+	tests := []struct {
+		name                       string
+		successful, failed         *int
+		wantSuccessful, wantFailed int
+	}{
+		{"left out", nil, nil, 3, 1},
+		{"zero keeps none", limit(0), limit(0), 0, 0},
+		{"given", limit(5), limit(2), 5, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := CronJob{SuccessfulHistoryLimit: tt.successful, FailedHistoryLimit: tt.failed}
+			c.SetDefaults()
+			if *c.SuccessfulHistoryLimit != tt.wantSuccessful || *c.FailedHistoryLimit != tt.wantFailed {
+				t.Errorf("limits = %d and %d, want %d and %d", *c.SuccessfulHistoryLimit, *c.FailedHistoryLimit, tt.wantSuccessful, tt.wantFailed)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package cronjob
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed five-field cron expression: minute, hour, day of
+// month, month and day of week. Each field is a bit set of the values it
+// matches.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar record whether the day fields were "*". When both
+	// day fields are restricted a time matches if either of them does.
+	domStar, dowStar bool
+}
+
+type bounds struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minutes = bounds{0, 59, nil}
+	hours   = bounds{0, 23, nil}
+	doms    = bounds{1, 31, nil}
+	months  = bounds{1, 12, map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// Day of week accepts 7 as well as 0 for Sunday.
+	dows = bounds{0, 7, map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// Parse parses a standard five-field cron expression such as "30 2 * * 1-5".
+// Fields accept "*", numbers, ranges, lists and steps, and month and day of
+// week fields also accept three letter names.
+func Parse(spec string) (*Schedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields, has %d", spec, len(fields))
+	}
+
+	s := &Schedule{
+		domStar: fields[2] == "*",
+		dowStar: fields[4] == "*",
+	}
+
+	var err error
+	for i, f := range []struct {
+		set *uint64
+		b   bounds
+	}{
+		{&s.minute, minutes},
+		{&s.hour, hours},
+		{&s.dom, doms},
+		{&s.month, months},
+		{&s.dow, dows},
+	} {
+		*f.set, err = parseField(fields[i], f.b)
+		if err != nil {
+			return nil, fmt.Errorf("cron expression %q: %v", spec, err)
+		}
+	}
+
+	// Fold Sunday written as 7 onto 0.
+	if s.dow&(1<<7) != 0 {
+		s.dow = s.dow&^(1<<7) | 1
+	}
+
+	return s, nil
+}
+
+func parseField(field string, b bounds) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		bits, err := parseRange(part, b)
+		if err != nil {
+			return 0, err
+		}
+		set |= bits
+	}
+
+	return set, nil
+}
+
+// parseRange parses one list element: "*", "n", "n-m", each optionally
+// followed by "/step".
+func parseRange(part string, b bounds) (uint64, error) {
+	rng, step := part, 1
+	if i := strings.Index(part, "/"); i >= 0 {
+		var err error
+		rng = part[:i]
+		step, err = strconv.Atoi(part[i+1:])
+		if err != nil || step <= 0 {
+			return 0, fmt.Errorf("invalid step in %q", part)
+		}
+	}
+
+	lo, hi := b.min, b.max
+	if rng != "*" {
+		bounds := strings.SplitN(rng, "-", 2)
+		var err error
+		lo, err = parseValue(bounds[0], b)
+		if err != nil {
+			return 0, err
+		}
+
+		hi = lo
+		if len(bounds) == 2 {
+			hi, err = parseValue(bounds[1], b)
+			if err != nil {
+				return 0, err
+			}
+		} else if step > 1 {
+			// "n/step" runs from n to the end of the range.
+			hi = b.max
+		}
+	}
+
+	if lo > hi {
+		return 0, fmt.Errorf("invalid range %q", part)
+	}
+
+	var bits uint64
+	for v := lo; v <= hi; v += step {
+		bits |= 1 << uint(v)
+	}
+
+	return bits, nil
+}
+
+func parseValue(v string, b bounds) (int, error) {
+	if n, ok := b.names[strings.ToLower(v)]; ok {
+		return n, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", v)
+	}
+
+	if n < b.min || n > b.max {
+		return 0, fmt.Errorf("value %d out of range %d-%d", n, b.min, b.max)
+	}
+
+	return n, nil
+}
+
+// Next returns the first time after t that matches the schedule, or the zero
+// time if there is none within the next five years. Times are matched in t's
+// location: a time skipped by a daylight saving change never matches, and one
+// repeated by it matches both times.
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = advance(t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location()))
+			continue
+		}
+
+		if !s.dayMatches(t) {
+			t = advance(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location()))
+			continue
+		}
+
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = advance(t, time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location()))
+			continue
+		}
+
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+// advance returns next, the start of a later month, day or hour than t. When
+// next falls in the gap left by a daylight saving change time.Date may move
+// it back to t or before, so the start of the hour after t is returned
+// instead.
+func advance(t, next time.Time) time.Time {
+	if next.After(t) {
+		return next
+	}
+
+	return t.Add(time.Duration(60-t.Minute()) * time.Minute)
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+
+	return dom || dow
+}
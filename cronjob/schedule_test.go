@@ -0,0 +1,108 @@
+package cronjob
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{spec: "* * * * *"},
+		{spec: "0,15,30,45 */2 1-15 jan-jun mon-fri"},
+		{spec: "5-50/5 1/3 * * 7"},
+		{spec: "* * *", wantErr: true},
+		{spec: "60 * * * *", wantErr: true},
+		{spec: "* 24 * * *", wantErr: true},
+		{spec: "* * 0 * *", wantErr: true},
+		{spec: "* * * 13 *", wantErr: true},
+		{spec: "* * * * 8", wantErr: true},
+		{spec: "10-5 * * * *", wantErr: true},
+		{spec: "*/0 * * * *", wantErr: true},
+		{spec: "* * * foo *", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q) error = %v, want error %v", tt.spec, err, tt.wantErr)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saoPaulo, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	utc := func(year int, month time.Month, day, hour, min int) time.Time {
+		return time.Date(year, month, day, hour, min, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name string
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		// Ranges, steps and lists.
+		{"every minute", "* * * * *", utc(2024, 5, 1, 10, 7), utc(2024, 5, 1, 10, 8)},
+		{"seconds are dropped", "* * * * *", utc(2024, 5, 1, 10, 7).Add(30 * time.Second), utc(2024, 5, 1, 10, 8)},
+		{"step", "*/15 * * * *", utc(2024, 5, 1, 10, 7), utc(2024, 5, 1, 10, 15)},
+		{"step wraps to next hour", "*/15 * * * *", utc(2024, 5, 1, 10, 45), utc(2024, 5, 1, 11, 0)},
+		{"range with step", "5-10/2 * * * *", utc(2024, 5, 1, 10, 6), utc(2024, 5, 1, 10, 7)},
+		{"range with step ends", "5-10/2 * * * *", utc(2024, 5, 1, 10, 9), utc(2024, 5, 1, 11, 5)},
+		{"start with step", "50/5 * * * *", utc(2024, 5, 1, 10, 56), utc(2024, 5, 1, 11, 50)},
+		{"list", "0 9,17 * * *", utc(2024, 5, 1, 9, 0), utc(2024, 5, 1, 17, 0)},
+		{"list wraps to next day", "0 9,17 * * *", utc(2024, 5, 1, 17, 0), utc(2024, 5, 2, 9, 0)},
+		{"list of ranges", "0 0 1-2,20-21 * *", utc(2024, 5, 2, 0, 0), utc(2024, 5, 20, 0, 0)},
+		{"month names", "0 0 1 jun,dec *", utc(2024, 6, 1, 0, 0), utc(2024, 12, 1, 0, 0)},
+		{"weekday names", "0 8 * * mon-fri", utc(2024, 5, 3, 8, 0), utc(2024, 5, 6, 8, 0)},
+		{"sunday as 7", "0 0 * * 7", utc(2024, 5, 1, 0, 0), utc(2024, 5, 5, 0, 0)},
+		{"sunday as 0", "0 0 * * 0", utc(2024, 5, 1, 0, 0), utc(2024, 5, 5, 0, 0)},
+
+		// A time matches either day field when both are restricted, and
+		// only the restricted one otherwise.
+		{"day of month or week, month matches", "0 0 13 * 5", utc(2024, 10, 12, 0, 0), utc(2024, 10, 13, 0, 0)},
+		{"day of month or week, week matches", "0 0 13 * 5", utc(2024, 10, 13, 0, 0), utc(2024, 10, 18, 0, 0)},
+		{"day of month only", "0 0 13 * *", utc(2024, 10, 13, 0, 0), utc(2024, 11, 13, 0, 0)},
+		{"day of week only", "0 0 * * 5", utc(2024, 10, 13, 0, 0), utc(2024, 10, 18, 0, 0)},
+
+		// Month ends.
+		{"next month", "0 0 1 * *", utc(2024, 1, 31, 12, 0), utc(2024, 2, 1, 0, 0)},
+		{"next year", "0 0 1 1 *", utc(2024, 12, 31, 23, 59), utc(2025, 1, 1, 0, 0)},
+		{"31st skips short months", "0 0 31 * *", utc(2024, 4, 1, 0, 0), utc(2024, 5, 31, 0, 0)},
+		{"29 february in a leap year", "0 0 29 2 *", utc(2023, 3, 1, 0, 0), utc(2024, 2, 29, 0, 0)},
+		{"30 february never comes", "0 0 30 2 *", utc(2023, 3, 1, 0, 0), time.Time{}},
+
+		// Daylight saving changes.
+		{"skipped hour does not match", "30 2 * * *", time.Date(2024, 3, 10, 0, 0, 0, 0, newYork), time.Date(2024, 3, 11, 2, 30, 0, 0, newYork)},
+		{"hours after the gap match", "0 * * * *", time.Date(2024, 3, 10, 1, 0, 0, 0, newYork), time.Date(2024, 3, 10, 3, 0, 0, 0, newYork)},
+		{"skipped midnight", "0 12 * * *", time.Date(2018, 11, 3, 13, 0, 0, 0, saoPaulo), time.Date(2018, 11, 4, 12, 0, 0, 0, saoPaulo)},
+		{"repeated hour matches first", "30 1 * * *", time.Date(2024, 11, 3, 0, 0, 0, 0, newYork), time.Date(2024, 11, 3, 1, 30, 0, 0, newYork)},
+		{"repeated hour matches again", "30 1 * * *", time.Date(2024, 11, 3, 1, 30, 0, 0, newYork), time.Date(2024, 11, 3, 1, 30, 0, 0, newYork).Add(time.Hour)},
+		{"hour after repeated one", "30 1 * * *", time.Date(2024, 11, 3, 1, 30, 0, 0, newYork).Add(time.Hour), time.Date(2024, 11, 4, 1, 30, 0, 0, newYork)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.spec, err)
+			}
+
+			got := s.Next(tt.from)
+			if !got.Equal(tt.want) {
+				t.Errorf("Next(%q, %v) = %v, want %v", tt.spec, tt.from, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package deployment
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/codding-buddha/mini-kube/task"
+	"github.com/google/uuid"
+)
+
+// historyLimit is how many revisions a deployment remembers for rollbacks.
+const historyLimit = 10
+
+// ownerPrefix starts the Owner of every task created for a deployment.
+const ownerPrefix = "deployment/"
+
+// Deployment keeps Replicas copies of its Template task running. Changing
+// the template starts a new revision that is rolled out according to
+// Strategy.
+type Deployment struct {
+	Name     string
+	Replicas int
+	Template task.Task
+	Strategy Strategy
+	// Paused stops a rollout from making progress until it is resumed.
+	Paused   bool
+	Revision int
+	History  []Revision
+	Status   Status
+}
+
+// Strategy bounds a rolling update. MaxSurge is how many tasks may run above
+// Replicas and MaxUnavailable how many fewer than Replicas may be available
+// while tasks of the old revision are replaced.
+type Strategy struct {
+	MaxSurge       int
+	MaxUnavailable int
+}
+
+// Revision is a template the deployment has rolled out.
+type Revision struct {
+	Number    int
+	Template  task.Task
+	CreatedAt time.Time
+}
+
+// Status is what the manager last observed for a deployment.
+type Status struct {
+	// Replicas counts tasks that are pending, scheduled or running.
+	Replicas        int
+	RunningReplicas int
+	// AvailableReplicas counts running tasks that are ready.
+	AvailableReplicas int
+	// UpdatedReplicas counts tasks of the current revision.
+	UpdatedReplicas int
+}
+
+// Owner is the value of task.Task.Owner for tasks created for d.
+func (d *Deployment) Owner() string {
+	return ownerPrefix + d.Name
+}
+
+// Owns reports whether t was created for a deployment.
+func Owns(t *task.Task) bool {
+	return strings.HasPrefix(t.Owner, ownerPrefix)
+}
+
+// SetDefaults fills in a strategy of one surge task and no unavailable ones
+// when none is given.
+func (d *Deployment) SetDefaults() {
+	if d.Strategy.MaxSurge == 0 && d.Strategy.MaxUnavailable == 0 {
+		d.Strategy.MaxSurge = 1
+	}
+}
+
+// SetTemplate makes t the deployment's template, starting a new revision if
+// it differs from the current one. It reports whether a revision was added.
+func (d *Deployment) SetTemplate(t task.Task) bool {
+	if d.Revision > 0 && reflect.DeepEqual(d.Template, t) {
+		return false
+	}
+
+	d.Template = t
+	d.Revision++
+	d.History = append(d.History, Revision{
+		Number:    d.Revision,
+		Template:  t,
+		CreatedAt: time.Now().UTC(),
+	})
+	if len(d.History) > historyLimit {
+		d.History = d.History[len(d.History)-historyLimit:]
+	}
+
+	return true
+}
+
+// Lookup returns the revision with the given number from the history.
+func (d *Deployment) Lookup(number int) (Revision, bool) {
+	for _, r := range d.History {
+		if r.Number == number {
+			return r, true
+		}
+	}
+
+	return Revision{}, false
+}
+
+// NewTask creates a pending task from the deployment's current template.
+func (d *Deployment) NewTask() task.Task {
+	return d.newTask(d.Template, d.Revision)
+}
+
+// NewTaskFrom creates a pending task from the template of revision r.
+func (d *Deployment) NewTaskFrom(r Revision) task.Task {
+	return d.newTask(r.Template, r.Number)
+}
+
+func (d *Deployment) newTask(template task.Task, revision int) task.Task {
+	t := template
+	t.ID = uuid.New()
+	t.Name = fmt.Sprintf("%s-%s", d.Name, t.ID.String()[:8])
+	t.Owner = d.Owner()
+	t.Revision = revision
+	t.State = task.Pending
+	t.ContainerID = ""
+	t.HostPorts = nil
+	t.Ready = false
+	t.RestartCount = 0
+	return t
+}
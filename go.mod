@@ -35,4 +35,8 @@ require (
 	gotest.tools/v3 v3.4.0 // indirect
 )
 
-require github.com/docker/docker v20.10.21+incompatible
+require (
+	github.com/boltdb/bolt v1.3.1
+	github.com/docker/docker v20.10.21+incompatible
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,75 @@
+package job
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/codding-buddha/mini-kube/task"
+	"github.com/google/uuid"
+)
+
+// Conditions a job moves through.
+const (
+	Running  = "Running"
+	Complete = "Complete"
+	Failed   = "Failed"
+)
+
+// Job runs its Template task until Completions of them exit successfully,
+// with at most Parallelism running at once. The job fails once more than
+// BackoffLimit tasks failed.
+type Job struct {
+	Name         string
+	Template     task.Task
+	Completions  int
+	Parallelism  int
+	BackoffLimit int
+	Status       Status
+}
+
+// Status is what the manager last observed for a job.
+type Status struct {
+	Condition string
+	// Active counts tasks that are pending, scheduled or running.
+	Active         int
+	Succeeded      int
+	Failed         int
+	StartTime      time.Time
+	CompletionTime time.Time
+}
+
+// SetDefaults fills in one completion and one task at a time when they are
+// not given.
+func (j *Job) SetDefaults() {
+	if j.Completions == 0 {
+		j.Completions = 1
+	}
+
+	if j.Parallelism == 0 {
+		j.Parallelism = 1
+	}
+}
+
+// Finished reports whether the job completed or failed.
+func (j *Job) Finished() bool {
+	return j.Status.Condition == Complete || j.Status.Condition == Failed
+}
+
+// Owner is the value of task.Task.Owner for tasks created for j.
+func (j *Job) Owner() string {
+	return fmt.Sprintf("job/%s", j.Name)
+}
+
+// NewTask creates a pending task from the job's template.
+func (j *Job) NewTask() task.Task {
+	t := j.Template
+	t.ID = uuid.New()
+	t.Name = fmt.Sprintf("%s-%s", j.Name, t.ID.String()[:8])
+	t.Owner = j.Owner()
+	t.State = task.Pending
+	t.ContainerID = ""
+	t.HostPorts = nil
+	t.RestartCount = 0
+	t.ExitCode = 0
+	return t
+}
@@ -1,51 +1,201 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
-	"strconv"
+	"os/signal"
+	"strings"
+	"syscall"
 
+	"github.com/codding-buddha/mini-kube/config"
 	"github.com/codding-buddha/mini-kube/manager"
 	"github.com/codding-buddha/mini-kube/task"
 	"github.com/codding-buddha/mini-kube/worker"
-	"github.com/golang-collections/collections/queue"
-	"github.com/google/uuid"
 )
 
+const usage = `Usage: mini-kube manager|worker [-config FILE] [flags]
+
+Commands:
+  manager   run the manager API, scheduler and reconcilers
+  worker    run a worker that registers with a manager and runs its tasks
+
+Settings come from, in increasing order of priority: built-in defaults, the
+YAML or JSON file given with -config, MINI_KUBE_* environment variables and
+flags. Run "mini-kube manager -h" or "mini-kube worker -h" to list them.
+`
+
 func main() {
-	whost := os.Getenv("MINI_KUBE_WORKER_HOST")
-	wport, err := strconv.Atoi(os.Getenv("MINI_KUBE_WORKER_PORT"))
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "manager":
+		err = runManager(os.Args[2:])
+	case "worker":
+		err = runWorker(os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "mini-kube: unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mini-kube: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runManager(args []string) error {
+	cfg := config.DefaultManager()
+	err := config.Parse("manager", args, cfg)
+	if err != nil {
+		return err
+	}
+
+	m, err := manager.New(cfg.Workers, cfg.Scheduler, cfg.Store, cfg.DataDir)
+	if err != nil {
+		return err
+	}
+
+	m.ProcessInterval = cfg.ProcessInterval
+	m.UpdateInterval = cfg.UpdateInterval
+	m.StatsInterval = cfg.StatsInterval
+	m.HealthCheckInterval = cfg.HealthCheckInterval
+	m.NodeCheckInterval = cfg.NodeCheckInterval
+	m.ReconcileInterval = cfg.ReconcileInterval
+	m.NotReadyTimeout = cfg.NotReadyTimeout
+	m.LostTimeout = cfg.LostTimeout
+	m.MaxConcurrentDispatch = cfg.MaxConcurrentDispatch
+	m.MaxRestarts = cfg.MaxRestarts
+	m.RestartBackoff = cfg.RestartBackoff
+	m.MaxRestartBackoff = cfg.MaxRestartBackoff
+	m.MaxTaskEvents = cfg.MaxTaskEvents
+
+	fmt.Printf("Starting manager and API at %v:%v\n", cfg.Host, cfg.Port)
+	mapi := &manager.Api{Address: cfg.Host, Port: cfg.Port, Manager: m}
+	m.Start()
+	err = serveUntilSignal(mapi.Start)
+	if err != nil {
+		return err
+	}
+
+	// New requests are refused first so nothing is queued while the loops
+	// finish. Streams such as followed logs can hold the API up until its
+	// deadline, so the manager gets its own to save its queue and close its
+	// stores.
+	fmt.Println("Stopping manager")
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	apiErr := mapi.Shutdown(ctx)
+
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer stopCancel()
+	return joinErrors(apiErr, m.Stop(stopCtx))
+}
+
+func runWorker(args []string) error {
+	cfg := config.DefaultWorker()
+	err := config.Parse("worker", args, cfg)
+	if err != nil {
+		return err
+	}
+
+	rt, err := newRuntime(cfg.Runtime, cfg.ProcessDir)
+	if err != nil {
+		return err
+	}
+
+	address := cfg.Address()
+	w, err := worker.New(address, cfg.Store, cfg.DataDir, rt)
+	if err != nil {
+		return err
+	}
+
+	w.Address = address
+	w.Manager = cfg.Manager
+	w.RunInterval = cfg.RunInterval
+	w.UpdateInterval = cfg.UpdateInterval
+	w.StatsInterval = cfg.StatsInterval
+	w.HeartbeatInterval = cfg.HeartbeatInterval
+	w.MaxConcurrentTasks = cfg.MaxConcurrentTasks
+	// Processes do not outlive the worker, so they are stopped cleanly
+	// rather than killed with it.
+	w.StopTasksOnExit = cfg.StopTasksOnExit || cfg.Runtime == "process"
+	err = w.Reconcile()
 	if err != nil {
-		panic(err)
+		fmt.Printf("Unable to reconcile tasks with the runtime: %v\n", err)
 	}
 
-	mhost := os.Getenv("MINI_KUBE_MANAGER_HOST")
-	mport, err := strconv.Atoi(os.Getenv("MINI_KUBE_MANAGER_PORT"))
+	fmt.Printf("Starting worker %v and API at %v:%v\n", address, cfg.Host, cfg.Port)
+	wapi := &worker.Api{Address: cfg.Host, Port: cfg.Port, Worker: w}
+	w.Start()
+	err = serveUntilSignal(wapi.Start)
+	if err != nil {
+		return err
+	}
 
+	// The API keeps answering the manager, refusing new work, until the
+	// tasks being started or stopped are done.
+	fmt.Println("Stopping worker")
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	err = w.Stop(ctx)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	fmt.Printf("Starting worker and API at %v:%v\n", whost, wport)
+	return wapi.Shutdown(ctx)
+}
+
+// joinErrors combines the errors that are not nil into one, or returns nil.
+func joinErrors(errs ...error) error {
+	var msgs []string
+	for _, err := range errs {
+		if err != nil {
+			msgs = append(msgs, err.Error())
+		}
+	}
 
-	w := worker.Worker{
-		Queue: *queue.New(),
-		Db:    make(map[uuid.UUID]*task.Task),
+	if len(msgs) == 0 {
+		return nil
 	}
 
-	wapi := worker.Api{Address: whost, Port: wport, Worker: &w}
-	go w.RunTasks()
-	go w.CollectStats()
-	go w.UpdateTasks()
-	go wapi.Start()
+	return errors.New(strings.Join(msgs, "; "))
+}
 
-	workers := []string{fmt.Sprintf("%s:%d", whost, wport)}
-	fmt.Printf("Starting manager and API at %v:%v\n", mhost, mport)
-	m := manager.New(workers)
-	mapi := manager.Api{Address: mhost, Port: mport, Manager: m}
-	go m.ProcessTasks()
-	go m.UpdateTasks()
-	go m.DoHealthChecks()
+// serveUntilSignal runs serve until the process receives SIGINT or SIGTERM.
+// It returns early with the error of serve if serving fails.
+func serveUntilSignal(serve func() error) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	mapi.Start()
+	errs := make(chan error, 1)
+	go func() {
+		errs <- serve()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// newRuntime creates the task runtime named by kind: "docker" or "process",
+// which keeps its state in dir.
+func newRuntime(kind string, dir string) (task.Runtime, error) {
+	switch kind {
+	case "process":
+		return task.NewProcess(dir)
+	default:
+		return task.NewDocker()
+	}
 }
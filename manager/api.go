@@ -1,8 +1,11 @@
 package manager
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"sync"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -12,6 +15,9 @@ type Api struct {
 	Port    int
 	Manager *Manager
 	Router  *chi.Mux
+	// mu guards server, which is set once Start is called.
+	mu     sync.Mutex
+	server *http.Server
 }
 
 func (api *Api) initRouter() {
@@ -20,12 +26,82 @@ func (api *Api) initRouter() {
 		r.Post("/", api.StartTaskHandler)
 		r.Get("/", api.GetTasksHandler)
 		r.Route("/{taskID}", func(r chi.Router) {
+			r.Get("/", api.GetTaskHandler)
 			r.Delete("/", api.StopTaskHandler)
+			r.Get("/logs", api.GetTaskLogsHandler)
+			r.Get("/events", api.GetTaskEventsHandler)
+		})
+	})
+	api.Router.Get("/events", api.GetEventsHandler)
+	api.Router.Route("/deployments", func(r chi.Router) {
+		r.Post("/", api.CreateDeploymentHandler)
+		r.Get("/", api.GetDeploymentsHandler)
+		r.Route("/{name}", func(r chi.Router) {
+			r.Get("/", api.GetDeploymentHandler)
+			r.Put("/", api.UpdateDeploymentHandler)
+			r.Delete("/", api.DeleteDeploymentHandler)
+			r.Post("/pause", api.PauseDeploymentHandler)
+			r.Post("/resume", api.ResumeDeploymentHandler)
+			r.Post("/rollback", api.RollbackDeploymentHandler)
+		})
+	})
+	api.Router.Route("/jobs", func(r chi.Router) {
+		r.Post("/", api.CreateJobHandler)
+		r.Get("/", api.GetJobsHandler)
+		r.Route("/{name}", func(r chi.Router) {
+			r.Get("/", api.GetJobHandler)
+			r.Delete("/", api.DeleteJobHandler)
+		})
+	})
+	api.Router.Route("/cronjobs", func(r chi.Router) {
+		r.Post("/", api.CreateCronJobHandler)
+		r.Get("/", api.GetCronJobsHandler)
+		r.Route("/{name}", func(r chi.Router) {
+			r.Get("/", api.GetCronJobHandler)
+			r.Delete("/", api.DeleteCronJobHandler)
+		})
+	})
+	api.Router.Post("/apply", api.ApplyHandler)
+	api.Router.Route("/nodes", func(r chi.Router) {
+		r.Get("/", api.GetNodesHandler)
+		r.Post("/", api.RegisterNodeHandler)
+		r.Route("/{nodeName}", func(r chi.Router) {
+			r.Post("/heartbeat", api.HeartbeatHandler)
+			r.Post("/cordon", api.CordonNodeHandler)
+			r.Post("/uncordon", api.UncordonNodeHandler)
+			r.Post("/drain", api.DrainNodeHandler)
 		})
 	})
 }
 
-func (api *Api) Start() {
+// Start serves the API until Shutdown is called.
+func (api *Api) Start() error {
 	api.initRouter()
-	http.ListenAndServe(fmt.Sprintf("%s:%d", api.Address, api.Port), api.Router)
+	api.mu.Lock()
+	api.server = &http.Server{
+		Addr:    fmt.Sprintf("%s:%d", api.Address, api.Port),
+		Handler: api.Router,
+	}
+	server := api.server
+	api.mu.Unlock()
+
+	err := server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// Shutdown stops accepting connections and waits for active requests to
+// finish until ctx ends.
+func (api *Api) Shutdown(ctx context.Context) error {
+	api.mu.Lock()
+	server := api.server
+	api.mu.Unlock()
+	if server == nil {
+		return nil
+	}
+
+	return server.Shutdown(ctx)
 }
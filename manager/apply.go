@@ -0,0 +1,320 @@
+package manager
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/codding-buddha/mini-kube/cronjob"
+	"github.com/codding-buddha/mini-kube/deployment"
+	"github.com/codding-buddha/mini-kube/job"
+	"github.com/codding-buddha/mini-kube/manifest"
+	"github.com/codding-buddha/mini-kube/task"
+	"github.com/google/uuid"
+)
+
+// Actions reported by Apply.
+const (
+	Created    = "created"
+	Configured = "configured"
+	Unchanged  = "unchanged"
+)
+
+// ApplyResult reports what applying one manifest did, or would do in a dry
+// run. Diff lists the spec fields that differ from the current resource.
+type ApplyResult struct {
+	Kind   string
+	Name   string
+	Action string
+	Diff   []string
+	Error  string `json:",omitempty"`
+}
+
+// Apply creates or updates the resource declared by each manifest, matching
+// existing resources by kind and name. Applying the same manifests again
+// changes nothing. With dryRun set only the results are computed.
+func (m *Manager) Apply(manifests []manifest.Manifest, dryRun bool) []ApplyResult {
+	var results []ApplyResult
+	for _, mf := range manifests {
+		r := ApplyResult{Kind: mf.Kind, Name: mf.Metadata.Name}
+		var err error
+		switch mf.Kind {
+		case manifest.Task:
+			err = m.applyTask(mf, dryRun, &r)
+		case manifest.Deployment:
+			err = m.applyDeployment(mf, dryRun, &r)
+		case manifest.Job:
+			err = m.applyJob(mf, dryRun, &r)
+		case manifest.CronJob:
+			err = m.applyCronJob(mf, dryRun, &r)
+		default:
+			err = fmt.Errorf("unknown kind %q", mf.Kind)
+		}
+
+		if err != nil {
+			r.Error = err.Error()
+		} else if !dryRun && r.Action != Unchanged {
+			log.Printf("Applied %s %s: %s", r.Kind, r.Name, r.Action)
+		}
+		results = append(results, r)
+	}
+
+	return results
+}
+
+// compare fills in the diff and action of r and reports whether the resource
+// needs to be created or changed.
+func compare(r *ApplyResult, exists bool, current, desired interface{}) (bool, error) {
+	var err error
+	if !exists {
+		current = nil
+	}
+
+	r.Diff, err = manifest.Diff(current, desired)
+	if err != nil {
+		return false, err
+	}
+
+	switch {
+	case !exists:
+		r.Action = Created
+	case len(r.Diff) == 0:
+		r.Action = Unchanged
+	default:
+		r.Action = Configured
+	}
+
+	return r.Action != Unchanged, nil
+}
+
+func (m *Manager) applyDeployment(mf manifest.Manifest, dryRun bool, r *ApplyResult) error {
+	d := deployment.Deployment{}
+	err := mf.DecodeSpec(&d)
+	if err != nil {
+		return err
+	}
+
+	d.Name = mf.Metadata.Name
+	err = validateDeployment(&d)
+	if err != nil {
+		return err
+	}
+
+	d.SetDefaults()
+	current, err := m.GetDeployment(d.Name)
+	exists := err == nil
+	var currentSpec interface{}
+	if exists {
+		currentSpec = deploymentSpec(current)
+	}
+
+	changed, err := compare(r, exists, currentSpec, deploymentSpec(&d))
+	if err != nil || !changed || dryRun {
+		return err
+	}
+
+	if !exists {
+		return m.CreateDeployment(&d)
+	}
+
+	return m.UpdateDeployment(d.Name, &d)
+}
+
+func deploymentSpec(d *deployment.Deployment) interface{} {
+	return struct {
+		Replicas int
+		Strategy deployment.Strategy
+		Paused   bool
+		Template task.Task
+	}{d.Replicas, d.Strategy, d.Paused, d.Template}
+}
+
+func (m *Manager) applyJob(mf manifest.Manifest, dryRun bool, r *ApplyResult) error {
+	j := job.Job{}
+	err := mf.DecodeSpec(&j)
+	if err != nil {
+		return err
+	}
+
+	j.Name = mf.Metadata.Name
+	err = validateJob(&j)
+	if err != nil {
+		return err
+	}
+
+	j.SetDefaults()
+	current, err := m.GetJob(j.Name)
+	exists := err == nil
+	var currentSpec interface{}
+	if exists {
+		currentSpec = jobSpec(current)
+	}
+
+	changed, err := compare(r, exists, currentSpec, jobSpec(&j))
+	if err != nil || !changed {
+		return err
+	}
+
+	if exists {
+		return fmt.Errorf("job %s cannot be changed once created, delete it first", j.Name)
+	}
+
+	if dryRun {
+		return nil
+	}
+
+	return m.CreateJob(&j)
+}
+
+func jobSpec(j *job.Job) interface{} {
+	return struct {
+		Completions  int
+		Parallelism  int
+		BackoffLimit int
+		Template     task.Task
+	}{j.Completions, j.Parallelism, j.BackoffLimit, j.Template}
+}
+
+func (m *Manager) applyCronJob(mf manifest.Manifest, dryRun bool, r *ApplyResult) error {
+	c := cronjob.CronJob{}
+	err := mf.DecodeSpec(&c)
+	if err != nil {
+		return err
+	}
+
+	c.Name = mf.Metadata.Name
+	c.SetDefaults()
+	err = validateCronJob(&c)
+	if err != nil {
+		return err
+	}
+
+	current, err := m.GetCronJob(c.Name)
+	exists := err == nil
+	var currentSpec interface{}
+	if exists {
+		currentSpec = cronJobSpec(current)
+	}
+
+	changed, err := compare(r, exists, currentSpec, cronJobSpec(&c))
+	if err != nil || !changed || dryRun {
+		return err
+	}
+
+	if !exists {
+		return m.CreateCronJob(&c)
+	}
+
+	return m.UpdateCronJob(c.Name, &c)
+}
+
+func cronJobSpec(c *cronjob.CronJob) interface{} {
+	return struct {
+		Schedule               string
+		ConcurrencyPolicy      string
+		SuccessfulHistoryLimit *int
+		FailedHistoryLimit     *int
+		Template               task.Task
+	}{c.Schedule, c.ConcurrencyPolicy, c.SuccessfulHistoryLimit, c.FailedHistoryLimit, c.Template}
+}
+
+// applyTask runs a standalone task under the manifest's name. Tasks cannot be
+// changed in place, so a changed spec stops the current task, if it is still
+// active, and starts a new one. A task that finished with the same spec is
+// left as it is.
+func (m *Manager) applyTask(mf manifest.Manifest, dryRun bool, r *ApplyResult) error {
+	t := task.Task{}
+	err := mf.DecodeSpec(&t)
+	if err != nil {
+		return err
+	}
+
+	t.Name = mf.Metadata.Name
+	err = t.Validate()
+	if err != nil {
+		return err
+	}
+
+	// The lookup and the create happen under one lock, so concurrent
+	// applies of the same task do not both create it.
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	desired := taskSpec(t)
+	current := m.findTask(t.Name)
+	var currentSpec interface{}
+	if current != nil {
+		currentSpec = taskSpec(*current)
+	}
+
+	changed, err := compare(r, current != nil, currentSpec, desired)
+	if err != nil || !changed || dryRun {
+		return err
+	}
+
+	if current != nil && isActive(current) {
+		if current.State == task.Pending {
+			m.setState(current, task.Completed, task.ReasonStopped, "replaced before it was placed")
+			current.FinishTime = time.Now().UTC()
+			m.putTask(current)
+		} else {
+			m.stopping[current.ID] = time.Now()
+			m.AddTask(task.TaskEvent{
+				ID:        uuid.New(),
+				State:     task.Completed,
+				Timestamp: time.Now(),
+				Task:      *current,
+			})
+		}
+	}
+
+	desired.ID = uuid.New()
+	desired.State = task.Pending
+	m.createTask(desired)
+	return nil
+}
+
+// findTask returns the latest standalone task with the given name: the
+// active one that is not being stopped, or else the one that finished last.
+// The caller must hold m.mu.
+func (m *Manager) findTask(name string) *task.Task {
+	var latest *task.Task
+	for _, t := range m.GetTasks() {
+		if t.Name != name || t.Owner != "" {
+			continue
+		}
+
+		if isActive(t) && !m.isStopping(t.ID) {
+			return t
+		}
+
+		if latest == nil || t.FinishTime.After(latest.FinishTime) {
+			latest = t
+		}
+	}
+
+	return latest
+}
+
+// taskSpec strips the fields of t the manager and workers fill in, leaving
+// what a manifest declares.
+func taskSpec(t task.Task) task.Task {
+	t.ID = uuid.UUID{}
+	t.ContainerID = ""
+	t.State = task.Pending
+	t.StartTime = time.Time{}
+	t.FinishTime = time.Time{}
+	t.HostPorts = nil
+	t.IP = ""
+	t.Ready = false
+	t.Health = task.Health{}
+	t.RestartCount = 0
+	t.Restarts = nil
+	t.RestartAt = time.Time{}
+	t.Status = ""
+	t.Reason = ""
+	t.Message = ""
+	t.ExitCode = 0
+	t.Owner = ""
+	t.Revision = 0
+	return t
+}
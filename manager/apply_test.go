@@ -0,0 +1,86 @@
+package manager
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/codding-buddha/mini-kube/manifest"
+	"github.com/codding-buddha/mini-kube/task"
+)
+
+func taskManifest(t *testing.T, name, image string) manifest.Manifest {
+	t.Helper()
+	spec, err := json.Marshal(map[string]string{"image": image})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return manifest.Manifest{Kind: manifest.Task, Metadata: manifest.Metadata{Name: name}, Spec: spec}
+}
+
+// applyConcurrently applies mf n times at once and counts the actions.
+func applyConcurrently(t *testing.T, m *Manager, mf manifest.Manifest, n int) map[string]int {
+	t.Helper()
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	actions := make(map[string]int)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results := m.Apply([]manifest.Manifest{mf}, false)
+			mu.Lock()
+			defer mu.Unlock()
+			for _, r := range results {
+				if r.Error != "" {
+					t.Errorf("apply: %s", r.Error)
+				}
+				actions[r.Action]++
+			}
+		}()
+	}
+	wg.Wait()
+
+	return actions
+}
+
+func TestConcurrentApplyCreatesTaskOnce(t *testing.T) {
+	const applies = 20
+	m := newTestManager(t)
+
+	actions := applyConcurrently(t, m, taskManifest(t, "web", "nginx:1"), applies)
+	if actions[Created] != 1 || actions[Unchanged] != applies-1 {
+		t.Errorf("actions = %v, want 1 created and %d unchanged", actions, applies-1)
+	}
+	if n := len(m.GetTasks()); n != 1 {
+		t.Fatalf("%d tasks after applying the same task %d times, want 1", n, applies)
+	}
+
+	// Mark the task as running so a changed spec has to stop it.
+	m.mu.Lock()
+	running := m.GetTasks()[0]
+	running.State = task.Running
+	m.assignTask(m.WorkerNodes[0].Name, running.ID)
+	m.putTask(running)
+	m.mu.Unlock()
+
+	actions = applyConcurrently(t, m, taskManifest(t, "web", "nginx:2"), applies)
+	if actions[Configured] != 1 || actions[Unchanged] != applies-1 {
+		t.Errorf("actions = %v, want 1 configured and %d unchanged", actions, applies-1)
+	}
+
+	var replacements int
+	for _, tk := range m.GetTasks() {
+		if tk.ID == running.ID {
+			continue
+		}
+		replacements++
+		if tk.Image != "nginx:2" {
+			t.Errorf("replacement runs %s, want nginx:2", tk.Image)
+		}
+	}
+	if replacements != 1 {
+		t.Errorf("%d replacement tasks created, want 1", replacements)
+	}
+}
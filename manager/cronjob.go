@@ -0,0 +1,204 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/codding-buddha/mini-kube/cronjob"
+	"github.com/codding-buddha/mini-kube/task"
+	"github.com/google/uuid"
+)
+
+var (
+	errCronJobExists   = errors.New("cron job already exists")
+	errCronJobNotFound = errors.New("cron job not found")
+)
+
+// GetCronJobs returns all cron jobs.
+func (m *Manager) GetCronJobs() []*cronjob.CronJob {
+	cs, err := m.CronJobDb.List()
+	if err != nil {
+		log.Printf("Error getting list of cron jobs: %v", err)
+		return nil
+	}
+
+	return cs.([]*cronjob.CronJob)
+}
+
+// GetCronJob returns the cron job with the given name.
+func (m *Manager) GetCronJob(name string) (*cronjob.CronJob, error) {
+	c, err := m.CronJobDb.Get(name)
+	if err != nil {
+		return nil, errCronJobNotFound
+	}
+
+	return c.(*cronjob.CronJob), nil
+}
+
+// CreateCronJob stores a new cron job. Its first run is the first time its
+// schedule matches after it was created.
+func (m *Manager) CreateCronJob(c *cronjob.CronJob) error {
+	c.SetDefaults()
+	err := validateCronJob(c)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	if _, err := m.GetCronJob(c.Name); err == nil {
+		m.mu.Unlock()
+		return errCronJobExists
+	}
+
+	c.Status = cronjob.Status{CreatedAt: time.Now()}
+	err = m.CronJobDb.Put(c.Name, c)
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Created cron job %v with schedule %q", c.Name, c.Schedule)
+	return nil
+}
+
+// UpdateCronJob replaces the schedule, policy, history limits and template
+// of the named cron job. Runs already started are left alone.
+func (m *Manager) UpdateCronJob(name string, c *cronjob.CronJob) error {
+	c.Name = name
+	c.SetDefaults()
+	err := validateCronJob(c)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	current, err := m.GetCronJob(name)
+	if err == nil {
+		current.Schedule = c.Schedule
+		current.ConcurrencyPolicy = c.ConcurrencyPolicy
+		current.SuccessfulHistoryLimit = c.SuccessfulHistoryLimit
+		current.FailedHistoryLimit = c.FailedHistoryLimit
+		current.Template = c.Template
+		err = m.CronJobDb.Put(name, current)
+	}
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	*c = *current
+	log.Printf("Updated cron job %v with schedule %q", name, c.Schedule)
+	return nil
+}
+
+// DeleteCronJob removes the named cron job. The reconciler then stops its
+// active tasks.
+func (m *Manager) DeleteCronJob(name string) error {
+	m.mu.Lock()
+	_, err := m.GetCronJob(name)
+	if err == nil {
+		err = m.CronJobDb.Delete(name)
+	}
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Deleted cron job %v", name)
+	m.wakeReconciler()
+	return nil
+}
+
+func validateCronJob(c *cronjob.CronJob) error {
+	if c.Name == "" {
+		return errors.New("cron job name is required")
+	}
+
+	if _, err := cronjob.Parse(c.Schedule); err != nil {
+		return err
+	}
+
+	switch c.ConcurrencyPolicy {
+	case cronjob.Allow, cronjob.Forbid, cronjob.Replace:
+	default:
+		return fmt.Errorf("unknown concurrency policy %q", c.ConcurrencyPolicy)
+	}
+
+	if *c.SuccessfulHistoryLimit < 0 || *c.FailedHistoryLimit < 0 {
+		return errors.New("history limits must not be negative")
+	}
+
+	return c.Template.Validate()
+}
+
+// reconcileCronJob starts a run of c when its schedule is due, applying its
+// concurrency policy, and removes finished tasks beyond its history limits.
+// Runs missed while the manager was down are not made up; only the latest
+// one is started. The caller must hold m.mu.
+func (m *Manager) reconcileCronJob(c *cronjob.CronJob, tasks []*task.Task) {
+	schedule, err := cronjob.Parse(c.Schedule)
+	if err != nil {
+		log.Printf("Skipping cron job %v: %v", c.Name, err)
+		return
+	}
+
+	var active, succeeded, failed []*task.Task
+	for _, t := range tasks {
+		switch {
+		case t.Succeeded():
+			succeeded = append(succeeded, t)
+		case t.State == task.Failed || t.State == task.Completed:
+			// Runs that were stopped or lost with their node did not
+			// succeed either.
+			failed = append(failed, t)
+		case isActive(t) && !m.isStopping(t.ID):
+			active = append(active, t)
+		}
+	}
+
+	now := time.Now()
+	last := c.Status.LastScheduleTime
+	if last.IsZero() {
+		last = c.Status.CreatedAt
+	}
+
+	due := schedule.Next(last)
+	if !due.IsZero() && !now.Before(due) {
+		for next := schedule.Next(due); !next.IsZero() && !now.Before(next); next = schedule.Next(next) {
+			due = next
+		}
+		c.Status.LastScheduleTime = due
+
+		switch {
+		case len(active) > 0 && c.ConcurrencyPolicy == cronjob.Forbid:
+			log.Printf("Skipping run of cron job %v at %v, %d tasks still active", c.Name, due, len(active))
+		default:
+			if c.ConcurrencyPolicy == cronjob.Replace {
+				for _, t := range active {
+					log.Printf("Stopping task %v of cron job %v to replace it", t.ID, c.Name)
+					m.stopOwnedTask(t)
+				}
+				active = nil
+			}
+
+			t := c.NewTask(due)
+			log.Printf("Starting task %v for cron job %v scheduled at %v", t.ID, c.Name, due)
+			m.createTask(t)
+			active = append(active, &t)
+		}
+	}
+
+	c.Status.Active = []uuid.UUID{}
+	for _, t := range active {
+		c.Status.Active = append(c.Status.Active, t.ID)
+	}
+
+	m.pruneTasks(succeeded, *c.SuccessfulHistoryLimit)
+	m.pruneTasks(failed, *c.FailedHistoryLimit)
+	err = m.CronJobDb.Put(c.Name, c)
+	if err != nil {
+		log.Printf("Error storing status of cron job %v: %v", c.Name, err)
+	}
+}
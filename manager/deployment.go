@@ -0,0 +1,345 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/codding-buddha/mini-kube/deployment"
+	"github.com/codding-buddha/mini-kube/task"
+)
+
+var (
+	errDeploymentExists   = errors.New("deployment already exists")
+	errDeploymentNotFound = errors.New("deployment not found")
+)
+
+// GetDeployments returns all deployments.
+func (m *Manager) GetDeployments() []*deployment.Deployment {
+	ds, err := m.DeploymentDb.List()
+	if err != nil {
+		log.Printf("Error getting list of deployments: %v", err)
+		return nil
+	}
+
+	return ds.([]*deployment.Deployment)
+}
+
+// GetDeployment returns the deployment with the given name.
+func (m *Manager) GetDeployment(name string) (*deployment.Deployment, error) {
+	d, err := m.DeploymentDb.Get(name)
+	if err != nil {
+		return nil, errDeploymentNotFound
+	}
+
+	return d.(*deployment.Deployment), nil
+}
+
+// CreateDeployment stores a new deployment at revision 1 and wakes the
+// reconciler to create its tasks.
+func (m *Manager) CreateDeployment(d *deployment.Deployment) error {
+	err := validateDeployment(d)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	if _, err := m.GetDeployment(d.Name); err == nil {
+		m.mu.Unlock()
+		return errDeploymentExists
+	}
+
+	template := d.Template
+	d.Revision = 0
+	d.History = nil
+	d.Status = deployment.Status{}
+	d.SetDefaults()
+	d.SetTemplate(template)
+	err = m.DeploymentDb.Put(d.Name, d)
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Created deployment %v with %d replicas", d.Name, d.Replicas)
+	m.wakeReconciler()
+	return nil
+}
+
+// UpdateDeployment replaces the replica count, strategy, paused flag and
+// template of the named deployment. A changed template starts a rolling
+// update to a new revision.
+func (m *Manager) UpdateDeployment(name string, d *deployment.Deployment) error {
+	d.Name = name
+	err := validateDeployment(d)
+	if err != nil {
+		return err
+	}
+
+	return m.modifyDeployment(name, d, func(current *deployment.Deployment) error {
+		current.Replicas = d.Replicas
+		current.Strategy = d.Strategy
+		current.Paused = d.Paused
+		current.SetDefaults()
+		if current.SetTemplate(d.Template) {
+			log.Printf("Rolling out revision %d of deployment %v", current.Revision, name)
+		}
+		return nil
+	})
+}
+
+// PauseDeployment stops the rollout of the named deployment where it is.
+func (m *Manager) PauseDeployment(name string, d *deployment.Deployment) error {
+	return m.modifyDeployment(name, d, func(current *deployment.Deployment) error {
+		current.Paused = true
+		return nil
+	})
+}
+
+// ResumeDeployment lets a paused rollout continue.
+func (m *Manager) ResumeDeployment(name string, d *deployment.Deployment) error {
+	return m.modifyDeployment(name, d, func(current *deployment.Deployment) error {
+		current.Paused = false
+		return nil
+	})
+}
+
+// RollbackDeployment rolls the named deployment out with the template of
+// revision, or of the revision before the current one when revision is 0.
+// The rollback is recorded as a new revision.
+func (m *Manager) RollbackDeployment(name string, revision int, d *deployment.Deployment) error {
+	return m.modifyDeployment(name, d, func(current *deployment.Deployment) error {
+		if revision == 0 {
+			for _, r := range current.History {
+				if r.Number < current.Revision {
+					revision = r.Number
+				}
+			}
+		}
+
+		r, ok := current.Lookup(revision)
+		if !ok || revision == current.Revision {
+			return fmt.Errorf("deployment %v has no earlier revision %d to roll back to", name, revision)
+		}
+
+		current.SetTemplate(r.Template)
+		log.Printf("Rolling deployment %v back to revision %d as revision %d", name, revision, current.Revision)
+		return nil
+	})
+}
+
+// modifyDeployment applies fn to the stored deployment and saves it, copying
+// the result into d.
+func (m *Manager) modifyDeployment(name string, d *deployment.Deployment, fn func(*deployment.Deployment) error) error {
+	m.mu.Lock()
+	current, err := m.GetDeployment(name)
+	if err == nil {
+		err = fn(current)
+	}
+	if err == nil {
+		err = m.DeploymentDb.Put(name, current)
+	}
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	*d = *current
+	m.wakeReconciler()
+	return nil
+}
+
+// DeleteDeployment removes the named deployment. The reconciler then stops
+// the tasks it owned.
+func (m *Manager) DeleteDeployment(name string) error {
+	m.mu.Lock()
+	_, err := m.GetDeployment(name)
+	if err == nil {
+		err = m.DeploymentDb.Delete(name)
+	}
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Deleted deployment %v", name)
+	m.wakeReconciler()
+	return nil
+}
+
+func validateDeployment(d *deployment.Deployment) error {
+	if d.Name == "" {
+		return errors.New("deployment name is required")
+	}
+
+	if d.Replicas < 0 {
+		return fmt.Errorf("replicas must not be negative, got %d", d.Replicas)
+	}
+
+	if d.Strategy.MaxSurge < 0 || d.Strategy.MaxUnavailable < 0 {
+		return errors.New("maxSurge and maxUnavailable must not be negative")
+	}
+
+	return d.Template.Validate()
+}
+
+// reconcileDeployment moves the tasks of d towards Replicas tasks of its
+// current revision. Tasks of older revisions and tasks on draining nodes are
+// only stopped while enough tasks stay available, and no more than MaxSurge
+// extra tasks are created. Tasks that exited or failed on their own keep
+// their place: their restart policy decides whether they run again, so a
+// crashing task backs off instead of being replaced. Of the other finished
+// tasks, only the latest finishedHistory are kept.
+// The caller must hold m.mu.
+func (m *Manager) reconcileDeployment(d *deployment.Deployment, tasks []*task.Task) {
+	var active, current, old, finished []*task.Task
+	replicas, running, available := 0, 0, 0
+	for _, t := range tasks {
+		if m.isStopping(t.ID) {
+			continue
+		}
+
+		// Tasks on draining nodes are replaced like those of older
+		// revisions.
+		outdated := t.Revision != d.Revision || m.onDrainingNode(t)
+		if !isActive(t) {
+			if !exited(t) || outdated {
+				if m.restartReason(t) != "" {
+					log.Printf("Cancelling restart of task %v of revision %d of deployment %v", t.ID, t.Revision, d.Name)
+					m.stopOwnedTask(t)
+				} else if isFinished(t) {
+					finished = append(finished, t)
+				}
+				continue
+			}
+		} else {
+			replicas++
+		}
+
+		active = append(active, t)
+		if t.State == task.Running {
+			running++
+		}
+		if t.Ready {
+			available++
+		}
+		if outdated {
+			old = append(old, t)
+		} else {
+			current = append(current, t)
+		}
+	}
+
+	d.Status = deployment.Status{
+		Replicas:          replicas,
+		RunningReplicas:   running,
+		AvailableReplicas: available,
+		UpdatedReplicas:   len(current),
+	}
+	err := m.DeploymentDb.Put(d.Name, d)
+	if err != nil {
+		log.Printf("Error storing status of deployment %v: %v", d.Name, err)
+	}
+	m.pruneTasks(finished, finishedHistory)
+
+	if d.Paused {
+		m.replaceMissing(d, active)
+		return
+	}
+
+	create := d.Replicas - len(current)
+	if room := d.Replicas + d.Strategy.MaxSurge - len(active); create > room {
+		create = room
+	}
+	for i := 0; i < create; i++ {
+		t := d.NewTask()
+		log.Printf("Creating task %v for revision %d of deployment %v", t.ID, d.Revision, d.Name)
+		m.createTask(t)
+	}
+
+	minAvailable := d.Replicas - d.Strategy.MaxUnavailable
+	m.sortForStop(old)
+	for _, t := range old {
+		if t.State == task.Scheduled {
+			continue
+		}
+
+		if t.Ready {
+			if available-1 < minAvailable {
+				continue
+			}
+			available--
+		}
+
+		log.Printf("Stopping task %v of revision %d of deployment %v", t.ID, t.Revision, d.Name)
+		m.stopOwnedTask(t)
+	}
+
+	excess := len(current) - d.Replicas
+	m.sortForStop(current)
+	for _, t := range current {
+		if excess <= 0 {
+			break
+		}
+
+		if t.State == task.Scheduled {
+			continue
+		}
+
+		log.Printf("Stopping task %v to scale deployment %v down to %d", t.ID, d.Name, d.Replicas)
+		m.stopOwnedTask(t)
+		excess--
+	}
+}
+
+// replaceMissing creates tasks for a paused deployment until it has Replicas
+// active tasks again. They use the revision most active tasks run, so a
+// paused rollout keeps its shape. The caller must hold m.mu.
+func (m *Manager) replaceMissing(d *deployment.Deployment, active []*task.Task) {
+	if len(active) >= d.Replicas {
+		return
+	}
+
+	counts := make(map[int]int)
+	revision := d.Revision
+	for _, t := range active {
+		counts[t.Revision]++
+		if counts[t.Revision] > counts[revision] {
+			revision = t.Revision
+		}
+	}
+
+	r, ok := d.Lookup(revision)
+	if !ok {
+		r = deployment.Revision{Number: d.Revision, Template: d.Template}
+	}
+
+	for i := len(active); i < d.Replicas; i++ {
+		t := d.NewTaskFrom(r)
+		log.Printf("Replacing task of paused deployment %v with %v from revision %d", d.Name, t.ID, r.Number)
+		m.createTask(t)
+	}
+}
+
+// exited reports whether t finished on its own, by exiting or failing, rather
+// than being stopped or lost with its node.
+func exited(t *task.Task) bool {
+	return t.State == task.Failed || t.Succeeded()
+}
+
+// sortForStop orders tasks so the cheapest to stop come first: tasks that
+// were never placed, then unavailable ones, then the newest. The caller must
+// hold m.mu.
+func (m *Manager) sortForStop(tasks []*task.Task) {
+	sort.SliceStable(tasks, func(i, j int) bool {
+		a, b := tasks[i], tasks[j]
+		if (a.State == task.Pending) != (b.State == task.Pending) {
+			return a.State == task.Pending
+		}
+		if a.Ready != b.Ready {
+			return !a.Ready
+		}
+		return a.StartTime.After(b.StartTime)
+	})
+}
@@ -0,0 +1,154 @@
+package manager
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/codding-buddha/mini-kube/task"
+	"github.com/google/uuid"
+)
+
+// EventFilter selects task events. Zero fields match every event.
+type EventFilter struct {
+	TaskID uuid.UUID
+	Node   string
+	// State and Reason are matched without regard to case.
+	State  string
+	Reason string
+	Since  time.Time
+	// Limit keeps only the most recent events.
+	Limit int
+}
+
+func (f EventFilter) matches(e *task.Event) bool {
+	return (f.TaskID == uuid.UUID{} || e.TaskID == f.TaskID) &&
+		(f.Node == "" || e.Node == f.Node) &&
+		(f.State == "" || strings.EqualFold(e.State.String(), f.State)) &&
+		(f.Reason == "" || strings.EqualFold(e.Reason, f.Reason)) &&
+		!e.Timestamp.Before(f.Since)
+}
+
+// GetEvents returns the task events f selects, oldest first. Events of a
+// single task are looked up by key instead of listing every event.
+func (m *Manager) GetEvents(f EventFilter) []*task.Event {
+	events := []*task.Event{}
+	if f.TaskID != (uuid.UUID{}) {
+		m.mu.Lock()
+		keys := append([]string(nil), m.events[f.TaskID]...)
+		m.mu.Unlock()
+
+		for _, key := range keys {
+			e, err := m.EventDb.Get(key)
+			if err == nil && f.matches(e.(*task.Event)) {
+				events = append(events, e.(*task.Event))
+			}
+		}
+	} else {
+		all, err := m.EventDb.List()
+		if err != nil {
+			log.Printf("Error getting list of events: %v", err)
+			return nil
+		}
+
+		for _, e := range all.([]*task.Event) {
+			if f.matches(e) {
+				events = append(events, e)
+			}
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+	if f.Limit > 0 && len(events) > f.Limit {
+		events = events[len(events)-f.Limit:]
+	}
+
+	return events
+}
+
+// recordEvent stores that t entered its current state, or that something
+// else happened to it, with reason and message. The caller must hold m.mu.
+func (m *Manager) recordEvent(t *task.Task, reason string, message string) {
+	e := &task.Event{
+		ID:        uuid.New(),
+		TaskID:    t.ID,
+		TaskName:  t.Name,
+		Timestamp: time.Now().UTC(),
+		State:     t.State,
+		Reason:    reason,
+		Message:   message,
+		Node:      m.TaskWorkerMap[t.ID],
+	}
+
+	err := m.EventDb.Put(e.ID.String(), e)
+	if err != nil {
+		log.Printf("Error storing event for task %v: %v", t.ID, err)
+		return
+	}
+
+	keys := append(m.events[t.ID], e.ID.String())
+	if m.MaxTaskEvents > 0 && len(keys) > m.MaxTaskEvents {
+		for _, key := range keys[:len(keys)-m.MaxTaskEvents] {
+			m.deleteEvent(key)
+		}
+		keys = keys[len(keys)-m.MaxTaskEvents:]
+	}
+	m.events[t.ID] = keys
+}
+
+// deleteEvents deletes the events of the task with id, once the task itself
+// is deleted. The caller must hold m.mu.
+func (m *Manager) deleteEvents(id uuid.UUID) {
+	for _, key := range m.events[id] {
+		m.deleteEvent(key)
+	}
+	delete(m.events, id)
+}
+
+func (m *Manager) deleteEvent(key string) {
+	err := m.EventDb.Delete(key)
+	if err != nil {
+		log.Printf("Error deleting event %v: %v", key, err)
+	}
+}
+
+// indexEvents finds the stored events of every task, so a persistent manager
+// keeps limiting them after a restart. Events of tasks that no longer exist
+// are deleted.
+func (m *Manager) indexEvents() error {
+	all, err := m.EventDb.List()
+	if err != nil {
+		return err
+	}
+
+	events := all.([]*task.Event)
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+	for _, e := range events {
+		if _, err := m.GetTask(e.TaskID); err != nil {
+			m.deleteEvent(e.ID.String())
+			continue
+		}
+		m.events[e.TaskID] = append(m.events[e.TaskID], e.ID.String())
+	}
+
+	return nil
+}
+
+// setState moves t to state and records the event. Transitions the state
+// machine refuses are logged and reported by returning false. The caller
+// must hold m.mu.
+func (m *Manager) setState(t *task.Task, state task.State, reason string, message string) bool {
+	err := t.SetState(state, reason, message)
+	if err != nil {
+		log.Printf("Error updating task: %v", err)
+		return false
+	}
+
+	m.recordEvent(t, reason, message)
+	return true
+}
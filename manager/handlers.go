@@ -3,11 +3,19 @@ package manager
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/codding-buddha/mini-kube/common"
+	"github.com/codding-buddha/mini-kube/cronjob"
+	"github.com/codding-buddha/mini-kube/deployment"
+	"github.com/codding-buddha/mini-kube/job"
+	"github.com/codding-buddha/mini-kube/manifest"
+	"github.com/codding-buddha/mini-kube/node"
+	"github.com/codding-buddha/mini-kube/stats"
 	"github.com/codding-buddha/mini-kube/task"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
@@ -34,6 +42,12 @@ func (api *Api) StartTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	err = te.Task.Validate()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	api.Manager.AddTask(te)
 	log.Printf("Added task %v\n", te.Task.ID)
 	w.WriteHeader(http.StatusCreated)
@@ -41,9 +55,92 @@ func (api *Api) StartTaskHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (api *Api) GetTasksHandler(w http.ResponseWriter, r *http.Request) {
+	tasks := api.Manager.GetTasks()
+	now := time.Now()
+	for _, t := range tasks {
+		t.SetStatus(now)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tasks)
+}
+
+func (api *Api) GetTaskHandler(w http.ResponseWriter, r *http.Request) {
+	tID, err := uuid.Parse(chi.URLParam(r, "taskID"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid task ID: %v", err))
+		return
+	}
+
+	t, err := api.Manager.GetTask(tID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("No task with ID %v found", tID))
+		return
+	}
+	t.SetStatus(time.Now())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(t)
+}
+
+// GetTaskEventsHandler answers with the events of the task, oldest first.
+func (api *Api) GetTaskEventsHandler(w http.ResponseWriter, r *http.Request) {
+	tID, err := uuid.Parse(chi.URLParam(r, "taskID"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid task ID: %v", err))
+		return
+	}
+
+	_, err = api.Manager.GetTask(tID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("No task with ID %v found", tID))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(api.Manager.GetEvents(EventFilter{TaskID: tID}))
+}
+
+// GetEventsHandler answers with the events of all tasks, oldest first. They
+// can be filtered by task, node, state and reason, limited to those since an
+// RFC 3339 time or a duration ago, and to the most recent limit of them.
+func (api *Api) GetEventsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := EventFilter{
+		Node:   q.Get("node"),
+		State:  q.Get("state"),
+		Reason: q.Get("reason"),
+	}
+
+	var err error
+	if v := q.Get("task"); v != "" {
+		f.TaskID, err = uuid.Parse(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid task ID: %v", err))
+			return
+		}
+	}
+
+	f.Since, err = task.ParseSince(q.Get("since"), time.Now())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if v := q.Get("limit"); v != "" {
+		f.Limit, err = strconv.Atoi(v)
+		if err != nil || f.Limit < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid limit %q", v))
+			return
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(api.Manager.GetTasks())
+	json.NewEncoder(w).Encode(api.Manager.GetEvents(f))
 }
 
 func (api *Api) StopTaskHandler(w http.ResponseWriter, r *http.Request) {
@@ -52,13 +149,15 @@ func (api *Api) StopTaskHandler(w http.ResponseWriter, r *http.Request) {
 	if taskID == "" {
 		log.Printf("No taskID passed in request.\n")
 		w.WriteHeader(http.StatusBadRequest)
+		return
 	}
 
 	tID, _ := uuid.Parse(taskID)
-	_, ok := api.Manager.TaskDb[tID]
-	if !ok {
+	taskToStop, err := api.Manager.GetTask(tID)
+	if err != nil {
 		log.Printf("No task with ID %v found.", tID)
-		w.WriteHeader(http.StatusBadRequest)
+		w.WriteHeader(http.StatusNotFound)
+		return
 	}
 
 	te := task.TaskEvent{
@@ -67,7 +166,6 @@ func (api *Api) StopTaskHandler(w http.ResponseWriter, r *http.Request) {
 		Timestamp: time.Now(),
 	}
 
-	taskToStop := api.Manager.TaskDb[tID]
 	taskCopy := *taskToStop
 	taskCopy.State = task.Completed
 	te.Task = *taskToStop
@@ -75,3 +173,372 @@ func (api *Api) StopTaskHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Added task event %v, to stop task %v\n", te.ID, taskToStop.ID)
 	w.WriteHeader(http.StatusAccepted)
 }
+
+func (api *Api) GetNodesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(api.Manager.GetNodes())
+}
+
+func (api *Api) RegisterNodeHandler(w http.ResponseWriter, r *http.Request) {
+	d := json.NewDecoder(r.Body)
+	reg := node.Registration{}
+	err := d.Decode(&reg)
+	if err != nil || reg.Name == "" || reg.Address == "" {
+		msg := fmt.Sprintf("Invalid node registration: %v\n", err)
+		log.Printf(msg)
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(common.ErrResponse{
+			HTTPStatusCode: http.StatusBadRequest,
+			Message:        msg,
+		})
+		return
+	}
+
+	n := api.Manager.RegisterNode(reg)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(n)
+}
+
+func (api *Api) CordonNodeHandler(w http.ResponseWriter, r *http.Request) {
+	n, err := api.Manager.CordonNode(chi.URLParam(r, "nodeName"))
+	writeNode(w, n, err)
+}
+
+func (api *Api) UncordonNodeHandler(w http.ResponseWriter, r *http.Request) {
+	n, err := api.Manager.UncordonNode(chi.URLParam(r, "nodeName"))
+	writeNode(w, n, err)
+}
+
+// DrainNodeHandler starts draining the node. It answers before the tasks
+// have moved; the node is drained once its TaskCount drops to 0.
+func (api *Api) DrainNodeHandler(w http.ResponseWriter, r *http.Request) {
+	n, err := api.Manager.DrainNode(chi.URLParam(r, "nodeName"))
+	writeNode(w, n, err)
+}
+
+func writeNode(w http.ResponseWriter, n *node.Node, err error) {
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(n)
+}
+
+func (api *Api) HeartbeatHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "nodeName")
+	s := stats.Stats{}
+	err := json.NewDecoder(r.Body).Decode(&s)
+	if err != nil {
+		msg := fmt.Sprintf("Error unmarshalling heartbeat: %v\n", err)
+		log.Printf(msg)
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(common.ErrResponse{
+			HTTPStatusCode: http.StatusBadRequest,
+			Message:        msg,
+		})
+		return
+	}
+
+	err = api.Manager.Heartbeat(name, s)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(common.ErrResponse{
+			HTTPStatusCode: http.StatusNotFound,
+			Message:        err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (api *Api) GetTaskLogsHandler(w http.ResponseWriter, r *http.Request) {
+	tID, err := uuid.Parse(chi.URLParam(r, "taskID"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(common.ErrResponse{
+			HTTPStatusCode: http.StatusBadRequest,
+			Message:        fmt.Sprintf("Invalid task ID: %v", err),
+		})
+		return
+	}
+
+	resp, err := api.Manager.TaskLogs(r.Context(), tID, r.URL.RawQuery)
+	if err != nil {
+		log.Printf("Error getting logs for task %v: %v", tID, err)
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(common.ErrResponse{
+			HTTPStatusCode: http.StatusNotFound,
+			Message:        err.Error(),
+		})
+		return
+	}
+	defer resp.Body.Close()
+
+	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	w.WriteHeader(resp.StatusCode)
+	common.CopyAndFlush(w, resp.Body)
+}
+
+func (api *Api) CreateDeploymentHandler(w http.ResponseWriter, r *http.Request) {
+	d := deployment.Deployment{}
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&d)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Error unmarshalling body: %v", err))
+		return
+	}
+
+	err = api.Manager.CreateDeployment(&d)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(d)
+}
+
+func (api *Api) GetDeploymentsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(api.Manager.GetDeployments())
+}
+
+func (api *Api) GetDeploymentHandler(w http.ResponseWriter, r *http.Request) {
+	d, err := api.Manager.GetDeployment(chi.URLParam(r, "name"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(d)
+}
+
+func (api *Api) UpdateDeploymentHandler(w http.ResponseWriter, r *http.Request) {
+	d := deployment.Deployment{}
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&d)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Error unmarshalling body: %v", err))
+		return
+	}
+
+	err = api.Manager.UpdateDeployment(chi.URLParam(r, "name"), &d)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(d)
+}
+
+func (api *Api) DeleteDeploymentHandler(w http.ResponseWriter, r *http.Request) {
+	err := api.Manager.DeleteDeployment(chi.URLParam(r, "name"))
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (api *Api) PauseDeploymentHandler(w http.ResponseWriter, r *http.Request) {
+	d := deployment.Deployment{}
+	err := api.Manager.PauseDeployment(chi.URLParam(r, "name"), &d)
+	writeDeployment(w, &d, err)
+}
+
+func (api *Api) ResumeDeploymentHandler(w http.ResponseWriter, r *http.Request) {
+	d := deployment.Deployment{}
+	err := api.Manager.ResumeDeployment(chi.URLParam(r, "name"), &d)
+	writeDeployment(w, &d, err)
+}
+
+// RollbackDeploymentHandler rolls back to the revision given by the optional
+// revision query parameter, or to the previous revision.
+func (api *Api) RollbackDeploymentHandler(w http.ResponseWriter, r *http.Request) {
+	revision := 0
+	if v := r.URL.Query().Get("revision"); v != "" {
+		var err error
+		revision, err = strconv.Atoi(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid revision %q: %v", v, err))
+			return
+		}
+	}
+
+	d := deployment.Deployment{}
+	err := api.Manager.RollbackDeployment(chi.URLParam(r, "name"), revision, &d)
+	writeDeployment(w, &d, err)
+}
+
+func writeDeployment(w http.ResponseWriter, d *deployment.Deployment, err error) {
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(d)
+}
+
+func (api *Api) CreateJobHandler(w http.ResponseWriter, r *http.Request) {
+	j := job.Job{}
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&j)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Error unmarshalling body: %v", err))
+		return
+	}
+
+	err = api.Manager.CreateJob(&j)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(j)
+}
+
+func (api *Api) GetJobsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(api.Manager.GetJobs())
+}
+
+func (api *Api) GetJobHandler(w http.ResponseWriter, r *http.Request) {
+	j, err := api.Manager.GetJob(chi.URLParam(r, "name"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(j)
+}
+
+func (api *Api) DeleteJobHandler(w http.ResponseWriter, r *http.Request) {
+	err := api.Manager.DeleteJob(chi.URLParam(r, "name"))
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (api *Api) CreateCronJobHandler(w http.ResponseWriter, r *http.Request) {
+	c := cronjob.CronJob{}
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&c)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Error unmarshalling body: %v", err))
+		return
+	}
+
+	err = api.Manager.CreateCronJob(&c)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(c)
+}
+
+func (api *Api) GetCronJobsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(api.Manager.GetCronJobs())
+}
+
+func (api *Api) GetCronJobHandler(w http.ResponseWriter, r *http.Request) {
+	c, err := api.Manager.GetCronJob(chi.URLParam(r, "name"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(c)
+}
+
+func (api *Api) DeleteCronJobHandler(w http.ResponseWriter, r *http.Request) {
+	err := api.Manager.DeleteCronJob(chi.URLParam(r, "name"))
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ApplyHandler applies the YAML or JSON manifests in the request body. With
+// dryRun=true it only reports what would change.
+func (api *Api) ApplyHandler(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Error reading body: %v", err))
+		return
+	}
+
+	manifests, err := manifest.Parse(data)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid manifest: %v", err))
+		return
+	}
+
+	results := api.Manager.Apply(manifests, r.URL.Query().Get("dryRun") == "true")
+	status := http.StatusOK
+	for _, res := range results {
+		if res.Error != "" {
+			status = http.StatusUnprocessableEntity
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(results)
+}
+
+func errorStatus(err error) int {
+	switch err {
+	case errDeploymentNotFound, errJobNotFound, errCronJobNotFound, errNodeNotFound:
+		return http.StatusNotFound
+	case errDeploymentExists, errJobExists, errCronJobExists:
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	log.Print(msg)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(common.ErrResponse{
+		HTTPStatusCode: status,
+		Message:        msg,
+	})
+}
@@ -0,0 +1,197 @@
+package manager
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/codding-buddha/mini-kube/job"
+	"github.com/codding-buddha/mini-kube/task"
+)
+
+const (
+	// jobBackoff is how long a job waits before retrying after its first
+	// failure. The wait doubles with every further failure up to
+	// maxJobBackoff.
+	jobBackoff    = 10 * time.Second
+	maxJobBackoff = 6 * time.Minute
+)
+
+var (
+	errJobExists   = errors.New("job already exists")
+	errJobNotFound = errors.New("job not found")
+)
+
+// GetJobs returns all jobs.
+func (m *Manager) GetJobs() []*job.Job {
+	js, err := m.JobDb.List()
+	if err != nil {
+		log.Printf("Error getting list of jobs: %v", err)
+		return nil
+	}
+
+	return js.([]*job.Job)
+}
+
+// GetJob returns the job with the given name.
+func (m *Manager) GetJob(name string) (*job.Job, error) {
+	j, err := m.JobDb.Get(name)
+	if err != nil {
+		return nil, errJobNotFound
+	}
+
+	return j.(*job.Job), nil
+}
+
+// CreateJob stores a new job and wakes the reconciler to start its tasks.
+func (m *Manager) CreateJob(j *job.Job) error {
+	err := validateJob(j)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	if _, err := m.GetJob(j.Name); err == nil {
+		m.mu.Unlock()
+		return errJobExists
+	}
+
+	j.SetDefaults()
+	j.Status = job.Status{Condition: job.Running, StartTime: time.Now().UTC()}
+	err = m.JobDb.Put(j.Name, j)
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Created job %v for %d completions", j.Name, j.Completions)
+	m.wakeReconciler()
+	return nil
+}
+
+// DeleteJob removes the named job. The reconciler then stops its tasks.
+func (m *Manager) DeleteJob(name string) error {
+	m.mu.Lock()
+	_, err := m.GetJob(name)
+	if err == nil {
+		err = m.JobDb.Delete(name)
+	}
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Deleted job %v", name)
+	m.wakeReconciler()
+	return nil
+}
+
+func validateJob(j *job.Job) error {
+	if j.Name == "" {
+		return errors.New("job name is required")
+	}
+
+	if j.Completions < 0 || j.Parallelism < 0 || j.BackoffLimit < 0 {
+		return errors.New("completions, parallelism and backoffLimit must not be negative")
+	}
+
+	return j.Template.Validate()
+}
+
+// reconcileJob counts the finished tasks of j, decides whether it completed
+// or failed, and otherwise starts tasks up to its parallelism, backing off
+// after failures. Tasks it does not count are kept up to finishedHistory.
+// The caller must hold m.mu.
+func (m *Manager) reconcileJob(j *job.Job, tasks []*task.Task) {
+	var active, uncounted []*task.Task
+	succeeded, failed := 0, 0
+	var lastFailure time.Time
+	for _, t := range tasks {
+		switch {
+		case t.Succeeded():
+			succeeded++
+		case t.State == task.Failed:
+			failed++
+			if t.FinishTime.After(lastFailure) {
+				lastFailure = t.FinishTime
+			}
+		case isActive(t) && !m.isStopping(t.ID):
+			active = append(active, t)
+		case isFinished(t):
+			// Tasks that were stopped or lost with their node count
+			// neither way.
+			uncounted = append(uncounted, t)
+		}
+	}
+	m.pruneTasks(uncounted, finishedHistory)
+
+	j.Status.Active = len(active)
+	j.Status.Succeeded = succeeded
+	j.Status.Failed = failed
+	if !j.Finished() {
+		switch {
+		case succeeded >= j.Completions:
+			log.Printf("Job %v completed after %d successful tasks", j.Name, succeeded)
+			j.Status.Condition = job.Complete
+			j.Status.CompletionTime = time.Now().UTC()
+		case failed > j.BackoffLimit:
+			log.Printf("Job %v failed after %d failed tasks", j.Name, failed)
+			j.Status.Condition = job.Failed
+			j.Status.CompletionTime = time.Now().UTC()
+		}
+	}
+
+	defer func() {
+		err := m.JobDb.Put(j.Name, j)
+		if err != nil {
+			log.Printf("Error storing status of job %v: %v", j.Name, err)
+		}
+	}()
+
+	if j.Finished() {
+		for _, t := range active {
+			log.Printf("Stopping task %v of finished job %v", t.ID, j.Name)
+			m.stopOwnedTask(t)
+		}
+		return
+	}
+
+	want := j.Parallelism
+	if remaining := j.Completions - succeeded; want > remaining {
+		want = remaining
+	}
+
+	create := want - len(active)
+	if create <= 0 {
+		return
+	}
+
+	if failed > 0 {
+		retryAt := lastFailure.Add(backoff(failed))
+		if time.Now().Before(retryAt) {
+			log.Printf("Job %v is backing off until %v after %d failures", j.Name, retryAt, failed)
+			return
+		}
+	}
+
+	for i := 0; i < create; i++ {
+		t := j.NewTask()
+		log.Printf("Creating task %v for job %v", t.ID, j.Name)
+		m.createTask(t)
+	}
+}
+
+// backoff is how long to wait before retrying after the given number of
+// failures.
+func backoff(failures int) time.Duration {
+	d := jobBackoff
+	for i := 1; i < failures && d < maxJobBackoff; i++ {
+		d *= 2
+	}
+
+	if d > maxJobBackoff {
+		return maxJobBackoff
+	}
+
+	return d
+}
@@ -0,0 +1,124 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codding-buddha/mini-kube/job"
+	"github.com/codding-buddha/mini-kube/task"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, jobBackoff},
+		{2, 2 * jobBackoff},
+		{3, 4 * jobBackoff},
+		{6, 32 * jobBackoff},
+		{7, maxJobBackoff},
+		{100, maxJobBackoff},
+	}
+
+	for _, tt := range tests {
+		if got := backoff(tt.failures); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+// jobTask returns a task of j in state, finished ago if it is not running.
+func jobTask(j *job.Job, state task.State, reason string, ago time.Duration) *task.Task {
+	t := j.NewTask()
+	t.State = state
+	t.Reason = reason
+	if state != task.Running {
+		t.FinishTime = time.Now().Add(-ago)
+	}
+
+	return &t
+}
+
+func TestReconcileJob(t *testing.T) {
+	succeeded := func(j *job.Job) *task.Task { return jobTask(j, task.Completed, task.ReasonCompleted, time.Hour) }
+	failed := func(ago time.Duration) func(j *job.Job) *task.Task {
+		return func(j *job.Job) *task.Task { return jobTask(j, task.Failed, task.ReasonError, ago) }
+	}
+	running := func(j *job.Job) *task.Task { return jobTask(j, task.Running, "", 0) }
+	stopped := func(j *job.Job) *task.Task { return jobTask(j, task.Completed, task.ReasonStopped, time.Hour) }
+
+	tests := []struct {
+		name         string
+		completions  int
+		parallelism  int
+		backoffLimit int
+		tasks        []func(*job.Job) *task.Task
+		condition    string
+		created      int
+		stopped      int
+	}{
+		{name: "starts up to parallelism", completions: 3, parallelism: 2, created: 2},
+		{name: "parallelism counts active tasks", completions: 3, parallelism: 2, tasks: []func(*job.Job) *task.Task{running}, created: 1},
+		{name: "no more than the remaining completions", completions: 3, parallelism: 2, tasks: []func(*job.Job) *task.Task{succeeded, succeeded}, created: 1},
+		{name: "stopped tasks count neither way", completions: 1, parallelism: 1, tasks: []func(*job.Job) *task.Task{stopped}, created: 1},
+		{
+			name:        "completes and stops the rest",
+			completions: 2, parallelism: 3,
+			tasks:     []func(*job.Job) *task.Task{succeeded, succeeded, running},
+			condition: job.Complete,
+			stopped:   1,
+		},
+		{
+			name:        "backs off after a recent failure",
+			completions: 1, parallelism: 1, backoffLimit: 3,
+			tasks: []func(*job.Job) *task.Task{failed(time.Second)},
+		},
+		{
+			name:        "retries once the backoff ran out",
+			completions: 1, parallelism: 1, backoffLimit: 3,
+			tasks:   []func(*job.Job) *task.Task{failed(time.Hour)},
+			created: 1,
+		},
+		{
+			name:        "fails past the backoff limit",
+			completions: 1, parallelism: 1, backoffLimit: 1,
+			tasks:     []func(*job.Job) *task.Task{failed(time.Hour), failed(time.Hour)},
+			condition: job.Failed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t)
+			j := &job.Job{
+				Name:         "batch",
+				Template:     task.Task{Image: "busybox"},
+				Completions:  tt.completions,
+				Parallelism:  tt.parallelism,
+				BackoffLimit: tt.backoffLimit,
+			}
+
+			var tasks []*task.Task
+			for _, newTask := range tt.tasks {
+				tk := newTask(j)
+				m.putTask(tk)
+				tasks = append(tasks, tk)
+			}
+
+			m.mu.Lock()
+			m.reconcileJob(j, tasks)
+			m.mu.Unlock()
+
+			if j.Status.Condition != tt.condition {
+				t.Errorf("condition = %q, want %q", j.Status.Condition, tt.condition)
+			}
+			if n := countState(ownedTasks(m, j.Owner()), task.Pending); n != tt.created {
+				t.Errorf("created %d tasks, want %d", n, tt.created)
+			}
+			if n := len(m.stopping); n != tt.stopped {
+				t.Errorf("stopped %d tasks, want %d", n, tt.stopped)
+			}
+		})
+	}
+}
@@ -2,138 +2,516 @@ package manager
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/codding-buddha/mini-kube/common"
+	"github.com/codding-buddha/mini-kube/node"
+	"github.com/codding-buddha/mini-kube/scheduler"
+	"github.com/codding-buddha/mini-kube/store"
 	"github.com/codding-buddha/mini-kube/task"
-	"github.com/docker/go-connections/nat"
 	"github.com/golang-collections/collections/queue"
 	"github.com/google/uuid"
 )
 
 type Manager struct {
 	Pending       queue.Queue
-	TaskDb        map[uuid.UUID]*task.Task
-	EventDb       map[uuid.UUID]*task.TaskEvent
+	TaskDb        store.Store
+	EventDb       store.Store
+	DeploymentDb  store.Store
+	JobDb         store.Store
+	CronJobDb     store.Store
 	Workers       []string
 	WorkerTaskMap map[string][]uuid.UUID
 	TaskWorkerMap map[uuid.UUID]string
-	LastWorkerId  int
+	WorkerNodes   []*node.Node
+	Scheduler     scheduler.Scheduler
+	// NotReadyTimeout is how long a node may miss heartbeats before it stops
+	// receiving new tasks; after LostTimeout it is considered gone and its
+	// tasks are rescheduled onto other nodes.
+	NotReadyTimeout time.Duration
+	LostTimeout     time.Duration
+	// MaxConcurrentDispatch limits how many tasks are posted to workers at once.
+	MaxConcurrentDispatch int
+	// ProcessInterval is how often the pending queue is checked when nothing
+	// wakes the dispatch loop.
+	ProcessInterval time.Duration
+	// ReconcileInterval is how often deployments, jobs and cron jobs are
+	// compared with their tasks when nothing wakes the reconciler.
+	ReconcileInterval time.Duration
+	// UpdateInterval is how often task states are fetched from workers,
+	// StatsInterval how often their stats are, HealthCheckInterval how often
+	// tasks are checked for restarts and NodeCheckInterval how often missed
+	// heartbeats are looked for.
+	UpdateInterval      time.Duration
+	StatsInterval       time.Duration
+	HealthCheckInterval time.Duration
+	NodeCheckInterval   time.Duration
+	// MaxRestarts caps how often a task is restarted, 0 meaning no cap.
+	// Restarts after the first back off exponentially from RestartBackoff
+	// up to MaxRestartBackoff.
+	MaxRestarts       int
+	RestartBackoff    time.Duration
+	MaxRestartBackoff time.Duration
+	// MaxTaskEvents is how many events are kept for each task, the oldest
+	// being dropped first, 0 meaning no limit.
+	MaxTaskEvents int
+	// events holds the keys of the stored events of each task, oldest
+	// first.
+	events map[uuid.UUID][]string
+	// allocations maps tasks whose resources are debited to the node holding them.
+	allocations map[uuid.UUID]string
+	// mu guards placement bookkeeping: the worker maps, the nodes and their
+	// fields, allocations, and read-modify-write updates of stored tasks. It
+	// also guards events.
+	mu        sync.Mutex
+	pendingMu sync.Mutex
+	wake      chan struct{}
+	// stopping holds tasks the reconciler asked to stop and when it did, so
+	// they are not counted as replicas while the worker stops them.
+	stopping map[uuid.UUID]time.Time
+	// restarted holds the start time of the run each task was last
+	// restarted from for failing its probes, so a stale report of that run
+	// does not restart it again.
+	restarted map[uuid.UUID]time.Time
+	// evicting maps standalone tasks on draining nodes to the replacements
+	// they are stopped for once those run.
+	evicting      map[uuid.UUID]uuid.UUID
+	reconcileWake chan struct{}
+	// done is closed by Stop to end the loops started by Start.
+	done     chan struct{}
+	stopOnce sync.Once
+	loops    sync.WaitGroup
+	// client sends the manager's requests to workers, timing out those a
+	// worker does not answer.
+	client *http.Client
+	// dispatching holds the tasks whose events are being sent to workers.
+	// It is guarded by pendingMu.
+	dispatching map[uuid.UUID]bool
+	// dispatchSem bounds the events sent at once to MaxConcurrentDispatch,
+	// and dispatches tracks the goroutines sending them.
+	dispatchSem chan struct{}
+	dispatches  sync.WaitGroup
 }
 
+// workerTimeout bounds each request the manager sends a worker, so a worker
+// that stops answering cannot hold up the manager's loops.
+const workerTimeout = 10 * time.Second
+
+// AddTask queues a task event and wakes the dispatch loop.
 func (m *Manager) AddTask(te task.TaskEvent) {
+	m.enqueue(te)
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Manager) enqueue(te task.TaskEvent) {
+	m.pendingMu.Lock()
+	defer m.pendingMu.Unlock()
 	m.Pending.Enqueue(te)
 }
 
+func (m *Manager) dequeue() (task.TaskEvent, bool) {
+	m.pendingMu.Lock()
+	defer m.pendingMu.Unlock()
+	if m.Pending.Len() == 0 {
+		return task.TaskEvent{}, false
+	}
+
+	return m.Pending.Dequeue().(task.TaskEvent), true
+}
+
+func (m *Manager) pendingLen() int {
+	m.pendingMu.Lock()
+	defer m.pendingMu.Unlock()
+	return m.Pending.Len()
+}
+
 func (m *Manager) GetTasks() []*task.Task {
-	tasks := make([]*task.Task, len(m.TaskDb))
-	for _, task := range m.TaskDb {
-		tasks = append(tasks, task)
+	tasks, err := m.TaskDb.List()
+	if err != nil {
+		log.Printf("Error getting list of tasks: %v", err)
+		return nil
+	}
+
+	return tasks.([]*task.Task)
+}
+
+// GetTask returns the task with the given id from the task store.
+func (m *Manager) GetTask(id uuid.UUID) (*task.Task, error) {
+	t, err := m.TaskDb.Get(id.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return t.(*task.Task), nil
+}
+
+// TaskLogs requests the logs of the task with id from the worker running it.
+// query is passed on unchanged so tail, since and follow reach the worker.
+func (m *Manager) TaskLogs(ctx context.Context, id uuid.UUID, query string) (*http.Response, error) {
+	m.mu.Lock()
+	w, ok := m.TaskWorkerMap[id]
+	n := m.getNode(w)
+	var api string
+	if n != nil {
+		api = n.Api
+	}
+	m.mu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("task %v is not placed on any worker", id)
 	}
 
-	return tasks
+	if n == nil {
+		return nil, fmt.Errorf("worker %v for task %v is not registered", w, id)
+	}
+
+	url := fmt.Sprintf("%s/tasks/%s/logs", api, id)
+	if query != "" {
+		url = fmt.Sprintf("%s?%s", url, query)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return http.DefaultClient.Do(req)
+}
+
+func (m *Manager) putTask(t *task.Task) {
+	if t.State != task.Running {
+		t.Ready = false
+	}
+
+	err := m.TaskDb.Put(t.ID.String(), t)
+	if err != nil {
+		log.Printf("Error storing task %v: %v", t.ID, err)
+	}
+}
+
+// SelectWorker asks the scheduler for the node best suited to run t.
+func (m *Manager) SelectWorker(t task.Task) (*node.Node, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.selectWorker(t)
 }
 
-func (m *Manager) SelectWorker() string {
-	nextWorker := (m.LastWorkerId + 1) % len(m.Workers)
-	m.LastWorkerId = nextWorker
-	return m.Workers[nextWorker]
+// selectWorker is SelectWorker for callers that already hold m.mu.
+func (m *Manager) selectWorker(t task.Task) (*node.Node, error) {
+	candidates := m.Scheduler.SelectCandidateNodes(t, m.schedulableNodes())
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no available candidates match resource request for task %v", t.ID)
+	}
+
+	scores := m.Scheduler.Score(t, candidates)
+	selectedNode := m.Scheduler.Pick(scores, candidates)
+	if selectedNode == nil {
+		return nil, fmt.Errorf("scheduler did not pick a node for task %v", t.ID)
+	}
+
+	return selectedNode, nil
 }
 
 func (m *Manager) updateTasks() {
-	for _, worker := range m.Workers {
+	apis := m.workerAPIs()
+	fmt.Printf("[Manager] Updating tasks from %d workers\n.", len(apis))
+	for worker, api := range apis {
 		log.Printf("Checking worker %v for task updates.", worker)
-		url := fmt.Sprintf("http://%s/tasks", worker)
-		resp, err := http.Get(url)
+		url := fmt.Sprintf("%s/tasks", api)
+		resp, err := m.client.Get(url)
 		if err != nil {
 			log.Printf("Error connecting to %v:%v", worker, err)
+			continue
 		}
 
 		if resp.StatusCode != http.StatusOK {
-			log.Printf("Error sending request: %v", err)
+			log.Printf("Error requesting tasks from %v: status %d", worker, resp.StatusCode)
+			resp.Body.Close()
+			continue
 		}
 
 		d := json.NewDecoder(resp.Body)
 		var tasks []*task.Task
 		err = d.Decode(&tasks)
+		resp.Body.Close()
 		if err != nil {
 			log.Printf("Error unmarshalling tasks: %s", err.Error())
+			continue
 		}
 
 		for _, t := range tasks {
 			log.Printf("Attempting to update task %v", t.ID)
-			_, ok := m.TaskDb[t.ID]
-			if !ok {
-				log.Printf("Task with ID %s not found\n", t.ID)
-				return
+			if m.updateTask(worker, t) {
+				log.Printf("Task %v was rescheduled away from %v, stopping stale copy", t.ID, worker)
+				m.stopTask(api, t.ID.String())
+			} else if t.State == task.Running && t.Health.Unhealthy {
+				m.maybeRestart(t.ID, time.Now())
 			}
+		}
+	}
+}
 
-			if m.TaskDb[t.ID].State != t.State {
-				m.TaskDb[t.ID].State = t.State
-			}
+// updateTask merges the state worker reports for t into the stored task. It
+// returns true when the worker runs a copy of t that now belongs elsewhere.
+func (m *Manager) updateTask(worker string, t *task.Task) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-			m.TaskDb[t.ID].StartTime = t.StartTime
-			m.TaskDb[t.ID].FinishTime = t.FinishTime
-			m.TaskDb[t.ID].ContainerID = t.ContainerID
-			m.TaskDb[t.ID].HostPorts = t.HostPorts
+	persisted, err := m.GetTask(t.ID)
+	if err != nil {
+		log.Printf("Task with ID %s not found\n", t.ID)
+		return false
+	}
+
+	if _, ok := m.TaskWorkerMap[t.ID]; !ok && persisted.State != task.Completed {
+		// Placements are not persisted, so after a restart the
+		// manager learns them back from the workers.
+		log.Printf("Adopting task %v running on %v", t.ID, worker)
+		m.assignTask(worker, t.ID)
+		n := m.getNode(worker)
+		if n != nil && isPlaced(t) {
+			m.allocate(n, *persisted)
+		}
+		if persisted.State == task.Stopping && isPlaced(t) {
+			// The stop was asked for before the restart and may never
+			// have reached the worker.
+			log.Printf("Task %v still runs on %v, stopping it again", t.ID, worker)
+			stop := *persisted
+			stop.State = task.Completed
+			m.AddTask(task.TaskEvent{
+				ID:        uuid.New(),
+				State:     task.Completed,
+				Timestamp: time.Now(),
+				Task:      stop,
+			})
+		}
+	}
+
+	if m.TaskWorkerMap[t.ID] != worker {
+		// The task was moved while this worker was unreachable, so
+		// the copy it still runs is stale.
+		return isPlaced(t)
+	}
+
+	state, reason := t.State, t.Reason
+	if persisted.State == task.Stopping && state == task.Failed {
+		// A task that fails while it is being stopped counts as
+		// stopped, so it is not restarted.
+		state, reason = task.Completed, task.ReasonStopped
+	}
+
+	// Completed tasks stay completed, and reports that predate a state
+	// the manager moved the task to, such as Stopping, are not applied.
+	if persisted.State != state && persisted.State != task.Completed {
+		if m.setState(persisted, state, reason, t.Message) && (state == task.Completed || state == task.Failed) {
+			m.release(*persisted)
+			delete(m.restarted, t.ID)
 		}
 	}
+
+	if t.Health.Unhealthy && !persisted.Health.Unhealthy {
+		m.recordEvent(persisted, task.ReasonUnhealthy, t.Health.Reason)
+	}
+
+	persisted.StartTime = t.StartTime
+	persisted.FinishTime = t.FinishTime
+	persisted.ContainerID = t.ContainerID
+	persisted.HostPorts = t.HostPorts
+	persisted.IP = t.IP
+	persisted.ExitCode = t.ExitCode
+	persisted.Ready = t.Ready
+	persisted.Health = t.Health
+	m.putTask(persisted)
+	return false
+}
+
+// unplace undoes the placement of t on a worker that did not take it.
+func (m *Manager) unplace(t task.Task) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.release(t)
+	m.unassignTask(t.ID)
+}
+
+// allocate debits the resources of t from node n, once per placement. The
+// caller must hold m.mu.
+func (m *Manager) allocate(n *node.Node, t task.Task) {
+	if _, ok := m.allocations[t.ID]; ok {
+		return
+	}
+
+	n.Allocate(t.Memory, t.Disk)
+	m.allocations[t.ID] = n.Name
+}
+
+// release credits the resources of t back to the node it was placed on. The
+// caller must hold m.mu.
+func (m *Manager) release(t task.Task) {
+	name, ok := m.allocations[t.ID]
+	if !ok {
+		return
+	}
+
+	delete(m.allocations, t.ID)
+	n := m.getNode(name)
+	if n == nil {
+		return
+	}
+
+	n.Release(t.Memory, t.Disk)
 }
 
 func (m *Manager) UpdateTasks() {
 	for {
-		fmt.Printf("[Manager] Updating tasks from %d workers\n.", len(m.Workers))
 		m.updateTasks()
-		time.Sleep(15 * time.Second)
+		if !m.sleep(m.UpdateInterval) {
+			return
+		}
 	}
 }
 
+// SendWork dispatches the task event at the head of the pending queue.
 func (m *Manager) SendWork() {
-	if m.Pending.Len() == 0 {
+	te, ok := m.dequeue()
+	if !ok {
 		log.Println("No work in the queue.")
 		return
 	}
 
-	w := m.SelectWorker()
-	e := m.Pending.Dequeue()
-	te := e.(task.TaskEvent)
+	m.sendWork(te)
+}
+
+func (m *Manager) sendWork(te task.TaskEvent) {
 	t := te.Task
 	log.Printf("Pulled %v off pending queue", t)
 
-	m.EventDb[te.ID] = &te
-	m.WorkerTaskMap[w] = append(m.WorkerTaskMap[w], te.Task.ID)
-	m.TaskWorkerMap[t.ID] = w
+	m.mu.Lock()
+	if w, ok := m.TaskWorkerMap[t.ID]; ok {
+		persisted, err := m.GetTask(t.ID)
+		n := m.getNode(w)
+		var api string
+		if n != nil {
+			api = n.Api
+		}
+		stop := err == nil && te.State == task.Completed && task.ValidStateTransition(persisted.State, task.Stopping)
+		if stop {
+			// A stopped task is not restarted, even if it was waiting
+			// to be.
+			m.setState(persisted, task.Stopping, task.ReasonStopRequested, "")
+			persisted.RestartAt = time.Time{}
+			m.putTask(persisted)
+		}
+		m.mu.Unlock()
+		if err != nil {
+			log.Printf("Task %v is placed on %v but missing from the task store", t.ID, w)
+			return
+		}
+
+		if stop {
+			if n != nil && m.stopTask(api, t.ID.String()) {
+				// The task stays Stopping until its worker stops it, so
+				// the stop is sent again until the worker takes it or
+				// is lost.
+				log.Printf("Queueing the stop of task %v on %v again", t.ID, w)
+				m.enqueue(te)
+			}
+			return
+		}
+
+		log.Printf("Invalid request: existing task %s is in state %v and cannot transition to state %v", t.ID, persisted.State, te.State)
+		return
+	}
+
+	if persisted, err := m.GetTask(t.ID); err == nil && persisted.State == task.Completed {
+		m.mu.Unlock()
+		log.Printf("Task %v was stopped before it was placed, dropping event %v", t.ID, te.ID)
+		return
+	}
+
+	if te.State == task.Completed {
+		// A stop of a task that is not placed, such as one waiting to be
+		// or a failed one whose worker refused it, has nothing to send.
+		persisted, err := m.GetTask(t.ID)
+		if err == nil && m.setState(persisted, task.Completed, task.ReasonStopped, "stopped while not placed") {
+			persisted.RestartAt = time.Time{}
+			persisted.FinishTime = time.Now().UTC()
+			m.putTask(persisted)
+		}
+		m.mu.Unlock()
+		return
+	}
+
+	n, err := m.selectWorker(t)
+	if err != nil {
+		m.mu.Unlock()
+		log.Printf("Unable to schedule task %v: %v", t.ID, err)
+		m.enqueue(te)
+		return
+	}
+
+	w := n.Name
+	m.assignTask(w, t.ID)
 	t.State = task.Scheduled
-	m.TaskDb[t.ID] = &t
+	m.setState(&t, task.Scheduled, task.ReasonScheduled, fmt.Sprintf("scheduled on node %s", w))
+	m.putTask(&t)
+	m.allocate(n, t)
+	api := n.Api
+	m.mu.Unlock()
+
 	data, err := json.Marshal(te)
 	if err != nil {
 		log.Printf("Unable to marshall object obj:%v, error:%v", t, err)
 	}
 
-	url := fmt.Sprintf("http://%s/tasks", w)
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(data))
+	url := fmt.Sprintf("%s/tasks", api)
+	resp, err := m.client.Post(url, "application/json", bytes.NewBuffer(data))
 	if err != nil {
 		log.Printf("Error connecting %v:%v", w, err)
-		m.Pending.Enqueue(t)
+		m.unplace(t)
+		m.enqueue(te)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusServiceUnavailable {
+		log.Printf("Worker %v is shutting down, queueing task %v again", w, t.ID)
+		m.unplace(t)
+		m.enqueue(te)
 		return
 	}
 
 	d := json.NewDecoder(resp.Body)
 	if resp.StatusCode != http.StatusCreated {
+		// Anything else is the worker refusing the task itself, which
+		// sending it again would not change.
+		msg := fmt.Sprintf("worker %s refused the task with status %d", w, resp.StatusCode)
 		e := common.ErrResponse{}
 		err := d.Decode(&e)
 		if err != nil {
 			fmt.Printf("Error decoding response: %s\n", err)
-			return
+		} else if e.Message != "" {
+			msg = fmt.Sprintf("worker %s refused the task: %s", w, strings.TrimSpace(e.Message))
 		}
 
-		log.Printf("Response error (%d): %s", e.HTTPStatusCode, e.Message)
+		log.Printf("Response error (%d): %s", resp.StatusCode, msg)
+		m.mu.Lock()
+		m.release(t)
+		if persisted, err := m.GetTask(t.ID); err == nil && m.setState(persisted, task.Failed, task.ReasonRejected, msg) {
+			m.putTask(persisted)
+		}
+		m.unassignTask(t.ID)
+		m.mu.Unlock()
 		return
 	}
 
@@ -147,140 +525,318 @@ func (m *Manager) SendWork() {
 	log.Printf("%#v\n", t)
 }
 
-func (m *Manager) ProcessTasks() {
-	for {
-		log.Println("Processing any task in the queue")
-		m.SendWork()
-		log.Println("Sleeping for 10 seconds")
-		time.Sleep(10 * time.Second)
-	}
+// assignTask records that the task with id now belongs to worker w. The
+// caller must hold m.mu.
+func (m *Manager) assignTask(w string, id uuid.UUID) {
+	m.unassignTask(id)
+	m.WorkerTaskMap[w] = append(m.WorkerTaskMap[w], id)
+	m.TaskWorkerMap[id] = w
 }
 
-func New(workers []string) *Manager {
-	taskDb := make(map[uuid.UUID]*task.Task)
-	eventDb := make(map[uuid.UUID]*task.TaskEvent)
-	workerTaskMap := make(map[string][]uuid.UUID)
-	taskWorkerMap := make(map[uuid.UUID]string)
-	for worker := range workers {
-		workerTaskMap[workers[worker]] = []uuid.UUID{}
+// unassignTask removes the task with id from the worker it was placed on.
+// The caller must hold m.mu.
+func (m *Manager) unassignTask(id uuid.UUID) {
+	w, ok := m.TaskWorkerMap[id]
+	if !ok {
+		return
 	}
 
-	return &Manager{
-		Pending:       *queue.New(),
-		Workers:       workers,
-		TaskDb:        taskDb,
-		EventDb:       eventDb,
-		WorkerTaskMap: workerTaskMap,
-		TaskWorkerMap: taskWorkerMap,
+	delete(m.TaskWorkerMap, id)
+	ids := m.WorkerTaskMap[w]
+	for i, tID := range ids {
+		if tID == id {
+			m.WorkerTaskMap[w] = append(ids[:i], ids[i+1:]...)
+			break
+		}
 	}
 }
 
-func (m *Manager) DoHealthChecks() {
-	for {
-		log.Println("Performing task health check")
-		m.doHealthChecks()
-		log.Println("Task health checks completed")
-		log.Println("Sleeping for 60 seconds")
-		time.Sleep(60 * time.Second)
+// stopTask asks the worker serving api to stop the task with taskID. It
+// reports whether the request should be sent again because the worker could
+// not be reached or is shutting down.
+func (m *Manager) stopTask(api string, taskID string) bool {
+	url := fmt.Sprintf("%s/tasks/%s", api, taskID)
+	req, err := http.NewRequest(http.MethodDelete, url, nil)
+	if err != nil {
+		log.Printf("Error creating request to delete task %s: %v", taskID, err)
+		return false
 	}
-}
-
-func (m *Manager) checkHealthTask(t task.Task) error {
-	log.Printf("Calling health check for task %s: %s\n", t.ID, t.HealthCheck)
 
-	w := m.TaskWorkerMap[t.ID]
-	hostPort := getHostPort(t.HostPorts)
-	if hostPort == nil {
-		return nil
+	resp, err := m.client.Do(req)
+	if err != nil {
+		log.Printf("Error connecting to worker at %s: %v", url, err)
+		return true
 	}
+	defer resp.Body.Close()
 
-	worker := strings.Split(w, ":")
-	url := fmt.Sprintf("http://%s:%s%s", worker[0], *hostPort, t.HealthCheck)
-	log.Printf("Calling health check for task %s: %s\n", t.ID, url)
-	resp, err := http.Get(url)
-	if err != nil {
-		msg := fmt.Sprintf("Error connecting to health check %s", url)
-		log.Println(msg)
-		return errors.New(msg)
+	if resp.StatusCode == http.StatusServiceUnavailable {
+		log.Printf("Worker at %s is shutting down, not stopping task %s yet", api, taskID)
+		return true
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		msg := fmt.Sprintf("Error health check for task %s did not return 200 \n", t.ID)
-		log.Println(msg)
-		return errors.New(msg)
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusNoContent {
+		log.Printf("Error sending request to stop task %s: status %d", taskID, resp.StatusCode)
+		return false
 	}
 
-	log.Printf("Task %s health check response: %v\n", t.ID, resp.StatusCode)
-	return nil
+	log.Printf("Task %s has been scheduled to be stopped", taskID)
+	return false
 }
 
-func (m *Manager) doHealthChecks() {
-	for _, t := range m.TaskDb {
-		if t.State == task.Running && t.RestartCount < 3 {
-			err := m.checkHealthTask(*t)
-			if err != nil {
-				if t.RestartCount < 3 {
-					m.restartTask(t)
-				}
-			}
-		} else if t.State == task.Failed && t.RestartCount < 3 {
-			m.restartTask(t)
+// rescheduleTasks moves every unfinished task placed on the lost node n back
+// onto the pending queue so the scheduler can place it on a healthy node.
+// The caller must hold m.mu.
+func (m *Manager) rescheduleTasks(n *node.Node) {
+	ids := append([]uuid.UUID{}, m.WorkerTaskMap[n.Name]...)
+	for _, id := range ids {
+		t, err := m.GetTask(id)
+		if err != nil || t.State == task.Completed {
+			continue
 		}
+
+		if t.State == task.Stopping {
+			// The stop was asked for, so the task counts as stopped and
+			// its restart policy does not bring it back.
+			log.Printf("Task %v was lost with node %v while stopping", id, n.Name)
+			m.setState(t, task.Completed, task.ReasonStopped, fmt.Sprintf("node %s was lost while the task stopped", n.Name))
+			m.release(*t)
+			m.unassignTask(t.ID)
+			m.putTask(t)
+			continue
+		}
+
+		log.Printf("Task %v was lost with node %v, rescheduling it", id, n.Name)
+		m.rescheduleTask(t, n.Name)
 	}
 }
 
-func (m *Manager) restartTask(t *task.Task) {
-	// Get the worker where the task was running
-	w := m.TaskWorkerMap[t.ID]
-	t.State = task.Scheduled
-	t.RestartCount++
-	// we need to override the existing task to ensure it has
-	// the current state
-	m.TaskDb[t.ID] = t
+// rescheduleTask detaches t from the lost worker w and queues it to be placed
+// again. The caller must hold m.mu.
+func (m *Manager) rescheduleTask(t *task.Task, w string) {
+	m.setState(t, task.Lost, task.ReasonNodeLost, fmt.Sprintf("node %s was lost", w))
+	m.release(*t)
+	m.unassignTask(t.ID)
+	t.ContainerID = ""
+	t.HostPorts = nil
+	m.putTask(t)
+	if t.Owner != "" {
+		// The task's owner replaces it with a new one.
+		return
+	}
 
-	te := task.TaskEvent{
+	rescheduled := *t
+	rescheduled.State = task.Scheduled
+	m.AddTask(task.TaskEvent{
 		ID:        uuid.New(),
-		State:     task.Running,
+		State:     task.Scheduled,
 		Timestamp: time.Now(),
-		Task:      *t,
+		Task:      rescheduled,
+	})
+}
+
+// ProcessTasks dispatches pending tasks as soon as they are added, falling
+// back to checking the queue every ProcessInterval.
+func (m *Manager) ProcessTasks() {
+	ticker := time.NewTicker(m.ProcessInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.wake:
+		case <-ticker.C:
+		case <-m.done:
+			return
+		}
+
+		m.processPending()
+	}
+}
+
+// processPending dispatches the pending events, posting up to
+// MaxConcurrentDispatch of them to workers at a time. Events for the same
+// task are sent in order by a single goroutine, and those for a task whose
+// earlier events are still being sent wait for the next round. It does not
+// wait for the events to be sent, so a worker that is slow to answer only
+// holds up its own tasks.
+func (m *Manager) processPending() {
+	if m.dispatchSem == nil {
+		m.dispatchSem = make(chan struct{}, m.MaxConcurrentDispatch)
 	}
 
-	data, err := json.Marshal(te)
-	if err != nil {
-		log.Printf("Unable to marshall task object: %v.", t)
+	var order []uuid.UUID
+	var busy []task.TaskEvent
+	batches := make(map[uuid.UUID][]task.TaskEvent)
+	m.pendingMu.Lock()
+	for m.Pending.Len() > 0 {
+		te := m.Pending.Dequeue().(task.TaskEvent)
+		id := te.Task.ID
+		if m.dispatching[id] {
+			busy = append(busy, te)
+			continue
+		}
+
+		if _, ok := batches[id]; !ok {
+			order = append(order, id)
+		}
+		batches[id] = append(batches[id], te)
+	}
+	for _, te := range busy {
+		m.Pending.Enqueue(te)
+	}
+	for _, id := range order {
+		m.dispatching[id] = true
 	}
+	m.pendingMu.Unlock()
 
-	url := fmt.Sprintf("http://%s/tasks", w)
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(data))
-	if err != nil {
-		log.Printf("Error connecting to %v: %v.", w, err)
-		m.Pending.Enqueue(t)
+	if len(order) == 0 {
 		return
 	}
 
-	d := json.NewDecoder(resp.Body)
-	if resp.StatusCode != http.StatusCreated {
-		e := common.ErrResponse{}
-		err := d.Decode(&e)
+	log.Printf("Dispatching %d pending tasks", len(order))
+	for _, id := range order {
+		m.dispatches.Add(1)
+		go func(id uuid.UUID, events []task.TaskEvent) {
+			defer m.dispatches.Done()
+			m.dispatchSem <- struct{}{}
+			for _, te := range events {
+				m.sendWork(te)
+			}
+			<-m.dispatchSem
+
+			m.pendingMu.Lock()
+			delete(m.dispatching, id)
+			m.pendingMu.Unlock()
+		}(id, batches[id])
+	}
+}
+
+// New creates a manager for the given workers that places tasks using the
+// scheduler named by schedulerType ("roundrobin", "greedy" or "epvm") and
+// keeps its state in a store of dbType ("memory" or "persistent"). Persistent
+// stores are kept in dataDir.
+func New(workers []string, schedulerType string, dbType string, dataDir string) (*Manager, error) {
+	var taskDb, eventDb, deploymentDb, jobDb, cronJobDb store.Store
+	switch dbType {
+	case store.Persistent:
+		ts, err := store.NewTaskStore(filepath.Join(dataDir, "tasks.db"), 0600, "tasks")
 		if err != nil {
-			fmt.Printf("Error decoding response: %s\n", err.Error())
+			return nil, fmt.Errorf("unable to create task store: %v", err)
 		}
-		log.Printf("Response error (%d): %s", e.HTTPStatusCode, e.Message)
-		return
+
+		es, err := store.NewEventStore(filepath.Join(dataDir, "events.db"), 0600, "events")
+		if err != nil {
+			ts.Close()
+			return nil, fmt.Errorf("unable to create event store: %v", err)
+		}
+
+		ds, err := store.NewDeploymentStore(filepath.Join(dataDir, "deployments.db"), 0600, "deployments")
+		if err != nil {
+			ts.Close()
+			es.Close()
+			return nil, fmt.Errorf("unable to create deployment store: %v", err)
+		}
+
+		js, err := store.NewJobStore(filepath.Join(dataDir, "jobs.db"), 0600, "jobs")
+		if err != nil {
+			ts.Close()
+			es.Close()
+			ds.Close()
+			return nil, fmt.Errorf("unable to create job store: %v", err)
+		}
+
+		cs, err := store.NewCronJobStore(filepath.Join(dataDir, "cronjobs.db"), 0600, "cronjobs")
+		if err != nil {
+			ts.Close()
+			es.Close()
+			ds.Close()
+			js.Close()
+			return nil, fmt.Errorf("unable to create cron job store: %v", err)
+		}
+
+		taskDb = ts
+		eventDb = es
+		deploymentDb = ds
+		jobDb = js
+		cronJobDb = cs
+	default:
+		taskDb = store.NewInMemoryTaskStore()
+		eventDb = store.NewInMemoryTaskEventStore()
+		deploymentDb = store.NewInMemoryDeploymentStore()
+		jobDb = store.NewInMemoryJobStore()
+		cronJobDb = store.NewInMemoryCronJobStore()
 	}
 
-	newTask := task.Task{}
-	err = d.Decode(&newTask)
+	workerTaskMap := make(map[string][]uuid.UUID)
+	taskWorkerMap := make(map[uuid.UUID]string)
+	var nodes []*node.Node
+	for worker := range workers {
+		workerTaskMap[workers[worker]] = []uuid.UUID{}
+		nAPI := fmt.Sprintf("http://%s", workers[worker])
+		nodes = append(nodes, node.NewNode(workers[worker], nAPI, "worker"))
+	}
+
+	m := &Manager{
+		Pending:               *queue.New(),
+		Workers:               workers,
+		TaskDb:                taskDb,
+		EventDb:               eventDb,
+		DeploymentDb:          deploymentDb,
+		JobDb:                 jobDb,
+		CronJobDb:             cronJobDb,
+		WorkerTaskMap:         workerTaskMap,
+		TaskWorkerMap:         taskWorkerMap,
+		WorkerNodes:           nodes,
+		Scheduler:             scheduler.New(schedulerType),
+		allocations:           make(map[uuid.UUID]string),
+		NotReadyTimeout:       30 * time.Second,
+		LostTimeout:           90 * time.Second,
+		MaxConcurrentDispatch: 4,
+		ProcessInterval:       10 * time.Second,
+		ReconcileInterval:     10 * time.Second,
+		UpdateInterval:        15 * time.Second,
+		StatsInterval:         15 * time.Second,
+		HealthCheckInterval:   5 * time.Second,
+		MaxRestarts:           3,
+		RestartBackoff:        10 * time.Second,
+		MaxRestartBackoff:     5 * time.Minute,
+		NodeCheckInterval:     10 * time.Second,
+		wake:                  make(chan struct{}, 1),
+		stopping:              make(map[uuid.UUID]time.Time),
+		restarted:             make(map[uuid.UUID]time.Time),
+		evicting:              make(map[uuid.UUID]uuid.UUID),
+		MaxTaskEvents:         100,
+		events:                make(map[uuid.UUID][]string),
+		reconcileWake:         make(chan struct{}, 1),
+		done:                  make(chan struct{}),
+		client:                &http.Client{Timeout: workerTimeout},
+		dispatching:           make(map[uuid.UUID]bool),
+	}
+
+	err := m.indexEvents()
 	if err != nil {
-		fmt.Printf("Error decoding response: %s\n", err.Error())
+		closeStores(taskDb, eventDb, deploymentDb, jobDb, cronJobDb)
+		return nil, fmt.Errorf("unable to read events: %v", err)
 	}
-	log.Printf("%#v\n", t)
+
+	m.requeuePending()
+	return m, nil
 }
 
-func getHostPort(ports nat.PortMap) *string {
-	for k, _ := range ports {
-		return &ports[k][0].HostPort
-	}
+// requeuePending queues stored tasks that were created but never placed,
+// which happens when the manager restarts with a persistent store.
+func (m *Manager) requeuePending() {
+	for _, t := range m.GetTasks() {
+		if t.State != task.Pending {
+			continue
+		}
 
-	return nil
+		log.Printf("Queueing pending task %v again", t.ID)
+		queued := *t
+		queued.State = task.Scheduled
+		m.enqueue(task.TaskEvent{
+			ID:        uuid.New(),
+			State:     task.Scheduled,
+			Timestamp: time.Now(),
+			Task:      queued,
+		})
+	}
 }
@@ -0,0 +1,473 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codding-buddha/mini-kube/deployment"
+	"github.com/codding-buddha/mini-kube/task"
+	"github.com/codding-buddha/mini-kube/worker"
+	"github.com/google/uuid"
+)
+
+// cluster is a manager and one worker running on a fake runtime, both
+// serving their APIs over HTTP.
+type cluster struct {
+	m    *Manager
+	w    *worker.Worker
+	rt   *task.FakeRuntime
+	url  string
+	ms   *httptest.Server
+	wapi *worker.Api
+}
+
+func newCluster(t *testing.T) *cluster {
+	t.Helper()
+	m, err := New(nil, "roundrobin", "memory", "")
+	if err != nil {
+		t.Fatalf("New manager: %v", err)
+	}
+	m.ProcessInterval = 10 * time.Millisecond
+	m.UpdateInterval = 10 * time.Millisecond
+	m.StatsInterval = 50 * time.Millisecond
+	m.HealthCheckInterval = 10 * time.Millisecond
+	m.NodeCheckInterval = 10 * time.Millisecond
+	m.ReconcileInterval = 10 * time.Millisecond
+	m.RestartBackoff = 20 * time.Millisecond
+	m.MaxRestartBackoff = 100 * time.Millisecond
+
+	api := &Api{Manager: m}
+	api.initRouter()
+	ms := httptest.NewServer(api.Router)
+
+	rt := task.NewFakeRuntime()
+	w, err := worker.New("worker-1", "memory", "", rt)
+	if err != nil {
+		t.Fatalf("New worker: %v", err)
+	}
+	w.RunInterval = 10 * time.Millisecond
+	w.UpdateInterval = 10 * time.Millisecond
+	w.StatsInterval = 50 * time.Millisecond
+	w.HeartbeatInterval = 20 * time.Millisecond
+	w.Manager = strings.TrimPrefix(ms.URL, "http://")
+
+	port := freePort(t)
+	w.Address = fmt.Sprintf("127.0.0.1:%d", port)
+	wapi := &worker.Api{Address: "127.0.0.1", Port: port, Worker: w}
+	go wapi.Start()
+	if !eventually(5*time.Second, func() bool {
+		resp, err := http.Get(fmt.Sprintf("http://%s/tasks", w.Address))
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return true
+	}) {
+		t.Fatal("worker API did not start")
+	}
+
+	return &cluster{m: m, w: w, rt: rt, url: ms.URL, ms: ms, wapi: wapi}
+}
+
+func (c *cluster) close() {
+	// Connections the client dialed but never used would hold up Shutdown.
+	http.DefaultClient.CloseIdleConnections()
+	c.wapi.Shutdown(context.Background())
+	c.ms.Close()
+}
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// eventually polls cond until it holds or the timeout runs out.
+func eventually(timeout time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return cond()
+}
+
+func post(t *testing.T, url string, v interface{}) *http.Response {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.Post(url, "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		t.Errorf("POST %s: %v", url, err)
+		return nil
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func request(t *testing.T, method string, url string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Errorf("%s %s: %v", method, url, err)
+		return
+	}
+	resp.Body.Close()
+}
+
+// TestConcurrentAPIAndLoops drives the manager API from several clients while
+// the dispatch, update, stats, node check, health check and reconcile loops
+// run against a live worker. It is meant to be run with -race.
+func TestConcurrentAPIAndLoops(t *testing.T) {
+	c := newCluster(t)
+	defer c.close()
+
+	c.m.Start()
+	c.w.Start()
+
+	if !eventually(5*time.Second, func() bool { return len(c.m.GetNodes()) == 1 }) {
+		t.Fatal("worker did not register")
+	}
+
+	resp := post(t, c.url+"/deployments", deployment.Deployment{
+		Name:     "web",
+		Replicas: 3,
+		Template: task.Task{Name: "web", Image: "nginx"},
+	})
+	if resp == nil || resp.StatusCode != http.StatusCreated {
+		t.Fatalf("creating deployment: %+v", resp)
+	}
+
+	const clients = 6
+	const tasksPerClient = 4
+	ids := make(chan uuid.UUID, clients*tasksPerClient)
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < tasksPerClient; j++ {
+				tk := task.Task{ID: uuid.New(), Name: fmt.Sprintf("task-%d-%d", i, j), State: task.Scheduled, Image: "busybox"}
+				resp := post(t, c.url+"/tasks", task.TaskEvent{ID: uuid.New(), State: task.Scheduled, Timestamp: time.Now(), Task: tk})
+				if resp != nil && resp.StatusCode != http.StatusCreated {
+					t.Errorf("POST /tasks: status %d", resp.StatusCode)
+				}
+				ids <- tk.ID
+
+				for _, path := range []string{"/tasks", "/events", "/nodes", "/deployments", "/tasks/" + tk.ID.String() + "/events"} {
+					request(t, http.MethodGet, c.url+path)
+				}
+				if j%2 == 1 {
+					request(t, http.MethodDelete, c.url+"/tasks/"+tk.ID.String())
+				}
+			}
+		}(i)
+	}
+
+	// Nodes are cordoned and containers crash while the clients work.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			request(t, http.MethodPost, c.url+"/nodes/worker-1/cordon")
+			request(t, http.MethodPost, c.url+"/nodes/worker-1/uncordon")
+			containers, _ := c.rt.List()
+			if len(containers) > 0 {
+				c.rt.Crash(containers[i%len(containers)].ID)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	wg.Wait()
+	close(ids)
+	var started []uuid.UUID
+	for id := range ids {
+		started = append(started, id)
+	}
+
+	settled := func() bool {
+		d, err := c.m.GetDeployment("web")
+		if err != nil || d.Status.RunningReplicas != 3 {
+			return false
+		}
+		for _, id := range started {
+			tk, err := c.m.GetTask(id)
+			if err != nil || tk.State == task.Pending || tk.State == task.Scheduled {
+				return false
+			}
+		}
+		return true
+	}
+	if !eventually(10*time.Second, settled) {
+		for _, tk := range c.m.GetTasks() {
+			t.Logf("task %v %s is %v (%s)", tk.ID, tk.Name, tk.State, tk.Reason)
+		}
+		t.Fatal("cluster did not settle")
+	}
+
+	for _, e := range c.m.GetEvents(EventFilter{}) {
+		if e.TaskID == (uuid.UUID{}) {
+			t.Errorf("event %v has no task", e.ID)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.m.Stop(ctx); err != nil {
+		t.Errorf("stopping manager: %v", err)
+	}
+	if err := c.w.Stop(ctx); err != nil {
+		t.Errorf("stopping worker: %v", err)
+	}
+}
+
+func getJSON(t *testing.T, url string, v interface{}) bool {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Errorf("GET %s: %v", url, err)
+		return false
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode == http.StatusOK && json.NewDecoder(resp.Body).Decode(v) == nil
+}
+
+// ownedTasks returns the tasks of m created for owner.
+func ownedTasks(m *Manager, owner string) []*task.Task {
+	var tasks []*task.Task
+	for _, t := range m.GetTasks() {
+		if t.Owner == owner {
+			tasks = append(tasks, t)
+		}
+	}
+
+	return tasks
+}
+
+// TestDeploymentCrashLoopBacksOff crashes the only replica of a deployment
+// every time it starts and checks that it is restarted in place with a
+// growing backoff instead of being replaced by new tasks.
+func TestDeploymentCrashLoopBacksOff(t *testing.T) {
+	c := newCluster(t)
+	defer c.close()
+	c.m.RestartBackoff = 40 * time.Millisecond
+	c.m.MaxRestartBackoff = 160 * time.Millisecond
+	c.m.MaxRestarts = 0
+
+	c.m.Start()
+	c.w.Start()
+	if !eventually(5*time.Second, func() bool { return len(c.m.GetNodes()) == 1 }) {
+		t.Fatal("worker did not register")
+	}
+
+	d := deployment.Deployment{
+		Name:     "crash",
+		Replicas: 1,
+		Template: task.Task{Name: "crash", Image: "busybox", RestartPolicy: task.RestartAlways},
+	}
+	resp := post(t, c.url+"/deployments", d)
+	if resp == nil || resp.StatusCode != http.StatusCreated {
+		t.Fatalf("creating deployment: %+v", resp)
+	}
+
+	const restarts = 6
+	backedOff := false
+	crashing := func() bool {
+		containers, _ := c.rt.List()
+		for _, container := range containers {
+			if container.Status == "running" {
+				c.rt.Crash(container.ID)
+			}
+		}
+
+		tasks := ownedTasks(c.m, d.Owner())
+		if len(tasks) != 1 {
+			return len(tasks) > 1
+		}
+
+		var served task.Task
+		if getJSON(t, fmt.Sprintf("%s/tasks/%s", c.url, tasks[0].ID), &served) && served.Status == task.CrashLoopBackOff {
+			backedOff = true
+		}
+		return tasks[0].RestartCount >= restarts
+	}
+	if !eventually(10*time.Second, crashing) {
+		t.Fatal("the replica was not restarted often enough")
+	}
+
+	tasks := ownedTasks(c.m, d.Owner())
+	if len(tasks) != 1 {
+		for _, tk := range tasks {
+			t.Logf("task %v is %v (%s)", tk.ID, tk.State, tk.Reason)
+		}
+		t.Fatalf("deployment has %d tasks, want its one replica restarted in place", len(tasks))
+	}
+
+	history := tasks[0].Restarts
+	for i := 1; i < len(history); i++ {
+		gap := history[i].Time.Sub(history[i-1].Time)
+		want := c.m.restartDelay(&task.Task{RestartCount: i}, time.Now())
+		if gap < want {
+			t.Errorf("restart %d came %v after the previous one, want at least %v", i+1, gap, want)
+		}
+	}
+	if !backedOff {
+		t.Error("the replica was never shown in CrashLoopBackOff")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.m.Stop(ctx); err != nil {
+		t.Errorf("stopping manager: %v", err)
+	}
+	if err := c.w.Stop(ctx); err != nil {
+		t.Errorf("stopping worker: %v", err)
+	}
+}
+
+// TestStopRetriedWhileWorkerShutsDown checks that a stop a draining worker
+// refuses is queued again and sent once the worker takes it.
+func TestStopRetriedWhileWorkerShutsDown(t *testing.T) {
+	var mu sync.Mutex
+	refuse := true
+	deletes := 0
+	ws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method != http.MethodDelete {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		deletes++
+		if refuse {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ws.Close()
+
+	m := newTestManager(t)
+	m.WorkerNodes[0].Api = ws.URL
+	tk := placeTask(m, task.Running, "")
+	stop := *tk
+	stop.State = task.Completed
+	m.AddTask(task.TaskEvent{ID: uuid.New(), State: task.Completed, Timestamp: time.Now(), Task: stop})
+
+	m.processPending()
+	m.dispatches.Wait()
+	if n := m.pendingLen(); n != 1 {
+		t.Fatalf("%d events pending after the worker refused the stop, want it queued again", n)
+	}
+
+	mu.Lock()
+	refuse = false
+	mu.Unlock()
+	m.processPending()
+	m.dispatches.Wait()
+	if n := m.pendingLen(); n != 0 {
+		t.Errorf("%d events pending after the worker took the stop", n)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if deletes != 2 {
+		t.Errorf("worker got %d stop requests, want 2", deletes)
+	}
+	stored, _ := m.GetTask(tk.ID)
+	if stored.State != task.Stopping {
+		t.Errorf("state = %v, want Stopping", stored.State)
+	}
+}
+
+// TestDispatchNotHeldUpByHungWorker checks that a worker that never answers
+// does not hold up events queued after the task sent to it, and that the
+// request to it times out so the task is queued again.
+func TestDispatchNotHeldUpByHungWorker(t *testing.T) {
+	release := make(chan struct{})
+	hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer hung.Close()
+	defer close(release)
+
+	stopped := make(chan struct{}, 1)
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			stopped <- struct{}{}
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer fast.Close()
+
+	m, err := New([]string{"hung", "fast"}, "roundrobin", "memory", "")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	m.client = &http.Client{Timeout: 500 * time.Millisecond}
+	m.WorkerNodes[0].Api = hung.URL
+	m.WorkerNodes[1].Api = fast.URL
+
+	// Place a running task on the fast worker, then keep new tasks off it
+	// so the next one goes to the hung worker.
+	running := &task.Task{ID: uuid.New(), Name: "running", Image: "busybox", State: task.Running}
+	m.mu.Lock()
+	m.assignTask("fast", running.ID)
+	m.allocate(m.WorkerNodes[1], *running)
+	m.putTask(running)
+	m.WorkerNodes[1].Unschedulable = true
+	m.mu.Unlock()
+
+	submitted := task.Task{ID: uuid.New(), Name: "submitted", Image: "busybox", State: task.Scheduled}
+	m.AddTask(task.TaskEvent{ID: uuid.New(), State: task.Scheduled, Timestamp: time.Now(), Task: submitted})
+	start := time.Now()
+	m.processPending()
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Errorf("dispatch waited %v for the hung worker", d)
+	}
+
+	stop := *running
+	stop.State = task.Completed
+	m.AddTask(task.TaskEvent{ID: uuid.New(), State: task.Completed, Timestamp: time.Now(), Task: stop})
+	m.processPending()
+	select {
+	case <-stopped:
+	case <-time.After(250 * time.Millisecond):
+		t.Error("the stop queued after the task sent to the hung worker was not sent")
+	}
+
+	m.dispatches.Wait()
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("request to the hung worker took %v, want it to time out", d)
+	}
+	if n := m.pendingLen(); n != 1 {
+		t.Errorf("%d events pending after the request timed out, want the task queued again", n)
+	}
+}
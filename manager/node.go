@@ -0,0 +1,235 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/codding-buddha/mini-kube/node"
+	"github.com/codding-buddha/mini-kube/stats"
+	"github.com/codding-buddha/mini-kube/task"
+	"github.com/google/uuid"
+)
+
+var errNodeNotFound = errors.New("node not found")
+
+// getNode returns the registered node with the given name. The caller must
+// hold m.mu.
+func (m *Manager) getNode(name string) *node.Node {
+	for _, n := range m.WorkerNodes {
+		if n.Name == name {
+			return n
+		}
+	}
+
+	return nil
+}
+
+// GetNodes returns a snapshot of the nodes known to the manager.
+func (m *Manager) GetNodes() []*node.Node {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	nodes := make([]*node.Node, 0, len(m.WorkerNodes))
+	for _, n := range m.WorkerNodes {
+		c := *n
+		nodes = append(nodes, &c)
+	}
+
+	return nodes
+}
+
+// workerAPIs returns the api address of every registered node keyed by name,
+// so callers can talk to workers without holding m.mu.
+func (m *Manager) workerAPIs() map[string]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	apis := make(map[string]string, len(m.WorkerNodes))
+	for _, n := range m.WorkerNodes {
+		apis[n.Name] = n.Api
+	}
+
+	return apis
+}
+
+// schedulableNodes returns the nodes that may receive new tasks. The caller
+// must hold m.mu.
+func (m *Manager) schedulableNodes() []*node.Node {
+	var nodes []*node.Node
+	for _, n := range m.WorkerNodes {
+		if n.State == node.Ready && !n.Unschedulable {
+			nodes = append(nodes, n)
+		}
+	}
+
+	return nodes
+}
+
+// RegisterNode adds a worker to the cluster, or refreshes it if a worker with
+// the same name registered before. It returns a snapshot of the node.
+func (m *Manager) RegisterNode(reg node.Registration) *node.Node {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	api := fmt.Sprintf("http://%s", reg.Address)
+	n := m.getNode(reg.Name)
+	if n == nil {
+		n = node.NewNode(reg.Name, api, "worker")
+		m.WorkerNodes = append(m.WorkerNodes, n)
+		m.Workers = append(m.Workers, reg.Name)
+		m.WorkerTaskMap[reg.Name] = []uuid.UUID{}
+		log.Printf("Registered node %v at %v", reg.Name, reg.Address)
+	} else {
+		n.Api = api
+		log.Printf("Node %v re-registered at %v", reg.Name, reg.Address)
+	}
+
+	n.RecordHeartbeat(reg.Stats)
+	c := *n
+	return &c
+}
+
+// CordonNode stops new tasks from being placed on the named node. Tasks
+// already there keep running.
+func (m *Manager) CordonNode(name string) (*node.Node, error) {
+	return m.modifyNode(name, func(n *node.Node) {
+		n.Unschedulable = true
+	})
+}
+
+// UncordonNode lets the named node receive new tasks again, ending a drain
+// that is in progress.
+func (m *Manager) UncordonNode(name string) (*node.Node, error) {
+	return m.modifyNode(name, func(n *node.Node) {
+		n.Unschedulable = false
+		n.Draining = false
+	})
+}
+
+// DrainNode cordons the named node and has the reconciler move its tasks
+// elsewhere. Standalone tasks are replaced by copies on other nodes and
+// stopped once their copy runs. Deployment tasks are replaced like in a
+// rolling update, so the deployment keeps as many tasks available as its
+// strategy requires. Tasks of jobs and cron jobs are left to finish. The
+// node is drained once its TaskCount is 0.
+func (m *Manager) DrainNode(name string) (*node.Node, error) {
+	n, err := m.modifyNode(name, func(n *node.Node) {
+		n.Unschedulable = true
+		n.Draining = true
+	})
+	if err == nil {
+		m.wakeReconciler()
+	}
+
+	return n, err
+}
+
+// modifyNode applies fn to the named node and returns a snapshot of it.
+func (m *Manager) modifyNode(name string, fn func(*node.Node)) (*node.Node, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := m.getNode(name)
+	if n == nil {
+		return nil, errNodeNotFound
+	}
+
+	fn(n)
+	log.Printf("Node %v is now unschedulable=%v draining=%v", name, n.Unschedulable, n.Draining)
+	c := *n
+	return &c, nil
+}
+
+// Heartbeat records that the named node is alive along with its latest stats.
+func (m *Manager) Heartbeat(name string, s stats.Stats) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := m.getNode(name)
+	if n == nil {
+		return fmt.Errorf("node %s is not registered", name)
+	}
+
+	if n.State != node.Ready {
+		log.Printf("Node %v is ready again", name)
+	}
+
+	n.RecordHeartbeat(s)
+	return nil
+}
+
+func (m *Manager) checkNodes() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for _, n := range m.WorkerNodes {
+		silence := now.Sub(n.LastHeartbeat)
+		switch {
+		case silence > m.LostTimeout:
+			if n.State != node.Lost {
+				log.Printf("Node %v has not sent a heartbeat for %v, marking it lost", n.Name, silence)
+				n.State = node.Lost
+				m.rescheduleTasks(n)
+			}
+		case silence > m.NotReadyTimeout:
+			if n.State != node.NotReady {
+				log.Printf("Node %v has not sent a heartbeat for %v, marking it not ready", n.Name, silence)
+				n.State = node.NotReady
+				m.markTasksUnknown(n)
+			}
+		}
+	}
+}
+
+// markTasksUnknown marks the tasks placed on n, which stopped sending
+// heartbeats, as Unknown until n reports them again. The caller must hold
+// m.mu.
+func (m *Manager) markTasksUnknown(n *node.Node) {
+	for _, id := range m.WorkerTaskMap[n.Name] {
+		t, err := m.GetTask(id)
+		if err != nil || !isPlaced(t) {
+			continue
+		}
+
+		m.setState(t, task.Unknown, task.ReasonNodeNotReady, fmt.Sprintf("node %s stopped sending heartbeats", n.Name))
+		m.putTask(t)
+	}
+}
+
+func (m *Manager) CheckNodes() {
+	for {
+		m.checkNodes()
+		if !m.sleep(m.NodeCheckInterval) {
+			return
+		}
+	}
+}
+
+func (m *Manager) updateNodeStats() {
+	for name, api := range m.workerAPIs() {
+		log.Printf("Collecting stats for node %v", name)
+		s, err := node.FetchStats(api)
+		if err != nil {
+			log.Printf("Error updating stats for node %v: %v", name, err)
+			continue
+		}
+
+		m.mu.Lock()
+		if n := m.getNode(name); n != nil {
+			n.UpdateStats(*s)
+		}
+		m.mu.Unlock()
+	}
+}
+
+func (m *Manager) UpdateNodeStats() {
+	for {
+		m.updateNodeStats()
+		if !m.sleep(m.StatsInterval) {
+			return
+		}
+	}
+}
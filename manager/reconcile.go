@@ -0,0 +1,281 @@
+package manager
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/codding-buddha/mini-kube/task"
+	"github.com/google/uuid"
+)
+
+// stopTimeout is how long a task asked to stop by the reconciler is left out
+// of the replica count before the stop is considered lost.
+const stopTimeout = time.Minute
+
+// finishedHistory is how many finished tasks a deployment or job keeps
+// besides those it counts, such as tasks that were stopped or lost with their
+// node.
+const finishedHistory = 10
+
+func (m *Manager) wakeReconciler() {
+	select {
+	case m.reconcileWake <- struct{}{}:
+	default:
+	}
+}
+
+// Reconcile keeps the tasks of every deployment, job and cron job in line
+// with what they ask for, running whenever one of them changes and at least every
+// ReconcileInterval.
+func (m *Manager) Reconcile() {
+	ticker := time.NewTicker(m.ReconcileInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.reconcileWake:
+		case <-ticker.C:
+		case <-m.done:
+			return
+		}
+
+		m.reconcile()
+	}
+}
+
+func (m *Manager) reconcile() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	owned := make(map[string][]*task.Task)
+	for _, t := range m.GetTasks() {
+		if since, ok := m.stopping[t.ID]; ok && (!isActive(t) || time.Since(since) > stopTimeout) {
+			delete(m.stopping, t.ID)
+		}
+		if t.Owner == "" {
+			continue
+		}
+		owned[t.Owner] = append(owned[t.Owner], t)
+	}
+
+	m.evictDrainingTasks()
+
+	for _, d := range m.GetDeployments() {
+		m.reconcileDeployment(d, owned[d.Owner()])
+		delete(owned, d.Owner())
+	}
+
+	for _, j := range m.GetJobs() {
+		m.reconcileJob(j, owned[j.Owner()])
+		delete(owned, j.Owner())
+	}
+
+	for _, c := range m.GetCronJobs() {
+		m.reconcileCronJob(c, owned[c.Owner()])
+		delete(owned, c.Owner())
+	}
+
+	// Whatever is left belongs to owners that were deleted. Tasks waiting
+	// to be restarted are stopped as well, which cancels the restart, and
+	// finished tasks are removed.
+	for owner, tasks := range owned {
+		var finished []*task.Task
+		for _, t := range tasks {
+			switch {
+			case m.isStopping(t.ID):
+			case isActive(t) || m.restartReason(t) != "":
+				log.Printf("Stopping task %v of deleted %v", t.ID, owner)
+				m.stopOwnedTask(t)
+			case isFinished(t):
+				finished = append(finished, t)
+			}
+		}
+		m.pruneTasks(finished, 0)
+	}
+}
+
+// createTask stores t as pending and queues it to be scheduled. The caller
+// must hold m.mu.
+func (m *Manager) createTask(t task.Task) {
+	message := "created"
+	if t.Owner != "" {
+		message = fmt.Sprintf("created by %s", t.Owner)
+	}
+	m.setState(&t, task.Pending, task.ReasonCreated, message)
+	m.putTask(&t)
+	queued := t
+	queued.State = task.Scheduled
+	m.AddTask(task.TaskEvent{
+		ID:        uuid.New(),
+		State:     task.Scheduled,
+		Timestamp: time.Now(),
+		Task:      queued,
+	})
+}
+
+// stopOwnedTask stops t on behalf of its owner, or of a drain for standalone
+// tasks. Tasks that were never placed are completed directly, and so are
+// finished tasks, which only have their restart cancelled. Failed tasks still
+// on a worker are stopped there to remove their container, and are moved to
+// Stopping at once so they are not restarted meanwhile. The caller must hold
+// m.mu.
+func (m *Manager) stopOwnedTask(t *task.Task) {
+	if t.State == task.Pending {
+		m.setState(t, task.Completed, task.ReasonStopped, "stopped before it was placed")
+		t.FinishTime = time.Now().UTC()
+		m.putTask(t)
+		return
+	}
+
+	if !isActive(t) {
+		_, placed := m.TaskWorkerMap[t.ID]
+		t.RestartAt = time.Time{}
+		if t.State != task.Failed || !placed {
+			m.setState(t, task.Completed, task.ReasonStopped, "stopped after it finished")
+			m.putTask(t)
+			return
+		}
+
+		m.setState(t, task.Stopping, task.ReasonStopRequested, "")
+		m.putTask(t)
+	}
+
+	m.stopping[t.ID] = time.Now()
+	m.AddTask(task.TaskEvent{
+		ID:        uuid.New(),
+		State:     task.Completed,
+		Timestamp: time.Now(),
+		Task:      *t,
+	})
+}
+
+// evictDrainingTasks replaces the standalone tasks running on draining nodes
+// with copies placed elsewhere. A task keeps running until its copy runs, and
+// stays where it is while no other node can take it. The caller must hold
+// m.mu.
+func (m *Manager) evictDrainingTasks() {
+	m.checkEvictions()
+
+	nodes := m.schedulableNodes()
+	if len(nodes) == 0 {
+		return
+	}
+
+	for _, n := range m.WorkerNodes {
+		if !n.Draining {
+			continue
+		}
+
+		for _, id := range m.WorkerTaskMap[n.Name] {
+			if _, ok := m.evicting[id]; ok {
+				continue
+			}
+
+			t, err := m.GetTask(id)
+			if err != nil || t.Owner != "" || t.State != task.Running || m.isStopping(id) {
+				continue
+			}
+
+			if len(m.Scheduler.SelectCandidateNodes(*t, nodes)) == 0 {
+				log.Printf("No node can take task %v from draining node %v", id, n.Name)
+				continue
+			}
+
+			replacement := *t
+			replacement.ID = uuid.New()
+			replacement.State = task.Pending
+			replacement.ContainerID = ""
+			replacement.HostPorts = nil
+			replacement.IP = ""
+			replacement.Ready = false
+			replacement.Health = task.Health{}
+			replacement.StartTime = time.Time{}
+			replacement.FinishTime = time.Time{}
+			replacement.RestartCount = 0
+			replacement.Restarts = nil
+			replacement.RestartAt = time.Time{}
+			replacement.ExitCode = 0
+			log.Printf("Replacing task %v on draining node %v with %v", id, n.Name, replacement.ID)
+			m.createTask(replacement)
+			m.evicting[id] = replacement.ID
+		}
+	}
+}
+
+// checkEvictions stops the tasks evicted from draining nodes whose
+// replacements run. Evictions whose replacement is gone are retried, and
+// those whose task finished or whose node stopped draining are called off.
+// The caller must hold m.mu.
+func (m *Manager) checkEvictions() {
+	for id, replacementID := range m.evicting {
+		t, err := m.GetTask(id)
+		r, rerr := m.GetTask(replacementID)
+		switch {
+		case err != nil || t.State != task.Running || !m.onDrainingNode(t):
+			if rerr == nil && isActive(r) && !m.isStopping(r.ID) {
+				log.Printf("Eviction of task %v called off, stopping its replacement %v", id, r.ID)
+				m.stopOwnedTask(r)
+			}
+			delete(m.evicting, id)
+		case rerr != nil || r.State == task.Completed:
+			log.Printf("Replacement %v of task %v is gone, replacing it again", replacementID, id)
+			delete(m.evicting, id)
+		case r.State == task.Running:
+			log.Printf("Replacement %v of task %v is running, stopping the original", r.ID, id)
+			m.stopOwnedTask(t)
+			delete(m.evicting, id)
+		}
+	}
+}
+
+// isFinished reports whether t is done running and only kept as history.
+func isFinished(t *task.Task) bool {
+	return t.State == task.Completed || t.State == task.Failed || t.State == task.Lost
+}
+
+// pruneTasks removes all but the keep most recently finished of tasks from
+// the manager. The caller must hold m.mu.
+func (m *Manager) pruneTasks(tasks []*task.Task, keep int) {
+	if len(tasks) <= keep {
+		return
+	}
+
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].FinishTime.After(tasks[j].FinishTime)
+	})
+	for _, t := range tasks[keep:] {
+		log.Printf("Removing finished task %v of %v", t.ID, t.Owner)
+		m.release(*t)
+		m.unassignTask(t.ID)
+		err := m.TaskDb.Delete(t.ID.String())
+		if err != nil {
+			log.Printf("Error removing task %v: %v", t.ID, err)
+			continue
+		}
+		m.deleteEvents(t.ID)
+	}
+}
+
+// onDrainingNode reports whether t is placed on a node being drained. The
+// caller must hold m.mu.
+func (m *Manager) onDrainingNode(t *task.Task) bool {
+	n := m.getNode(m.TaskWorkerMap[t.ID])
+	return n != nil && n.Draining
+}
+
+func (m *Manager) isStopping(id uuid.UUID) bool {
+	_, ok := m.stopping[id]
+	return ok
+}
+
+// isActive reports whether t is placed or waiting to be placed. Tasks on
+// workers the manager has not heard from lately are still counted.
+func isActive(t *task.Task) bool {
+	return t.State == task.Pending || isPlaced(t) || t.State == task.Unknown
+}
+
+// isPlaced reports whether t runs or is about to run on its worker.
+func isPlaced(t *task.Task) bool {
+	return t.State == task.Scheduled || t.State == task.Running || t.State == task.Restarting
+}
@@ -0,0 +1,115 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codding-buddha/mini-kube/cronjob"
+	"github.com/codding-buddha/mini-kube/deployment"
+	"github.com/codding-buddha/mini-kube/job"
+	"github.com/codding-buddha/mini-kube/task"
+	"github.com/google/uuid"
+)
+
+// putFinished stores n tasks of owner that were stopped, the latest last.
+func putFinished(m *Manager, owner string, n int) {
+	for i := 0; i < n; i++ {
+		m.putTask(&task.Task{
+			ID:         uuid.New(),
+			Name:       "finished",
+			Owner:      owner,
+			State:      task.Completed,
+			Reason:     task.ReasonStopped,
+			FinishTime: time.Now().Add(time.Duration(i) * time.Second),
+		})
+	}
+}
+
+func countState(tasks []*task.Task, state task.State) int {
+	n := 0
+	for _, t := range tasks {
+		if t.State == state {
+			n++
+		}
+	}
+
+	return n
+}
+
+func TestReconcilePrunesFinishedTasks(t *testing.T) {
+	m := newTestManager(t)
+	d := &deployment.Deployment{Name: "web", Replicas: 1, Template: task.Task{Image: "nginx"}}
+	if err := m.CreateDeployment(d); err != nil {
+		t.Fatal(err)
+	}
+	j := &job.Job{Name: "batch", Template: task.Task{Image: "busybox"}}
+	if err := m.CreateJob(j); err != nil {
+		t.Fatal(err)
+	}
+
+	putFinished(m, d.Owner(), finishedHistory+5)
+	putFinished(m, j.Owner(), finishedHistory+3)
+	putFinished(m, "deployment/deleted", 4)
+	m.reconcile()
+
+	tests := []struct {
+		owner    string
+		finished int
+	}{
+		{d.Owner(), finishedHistory},
+		{j.Owner(), finishedHistory},
+		{"deployment/deleted", 0},
+	}
+	for _, tt := range tests {
+		tasks := ownedTasks(m, tt.owner)
+		if n := countState(tasks, task.Completed); n != tt.finished {
+			t.Errorf("%s kept %d finished tasks, want %d", tt.owner, n, tt.finished)
+		}
+	}
+
+	for _, tk := range ownedTasks(m, d.Owner()) {
+		if tk.State == task.Completed && tk.FinishTime.Before(time.Now().Add(4*time.Second)) {
+			t.Errorf("task %v finished at %v was kept over later ones", tk.ID, tk.FinishTime)
+		}
+	}
+	if n := countState(ownedTasks(m, d.Owner()), task.Pending); n != 1 {
+		t.Errorf("deployment has %d pending tasks, want its replica", n)
+	}
+}
+
+func TestReconcileCronJobZeroHistoryLimits(t *testing.T) {
+	m := newTestManager(t)
+	none := 0
+	c := &cronjob.CronJob{
+		Name:                   "nightly",
+		Schedule:               "0 0 1 1 *",
+		SuccessfulHistoryLimit: &none,
+		FailedHistoryLimit:     &none,
+		Template:               task.Task{Image: "busybox"},
+	}
+	if err := m.CreateCronJob(c); err != nil {
+		t.Fatal(err)
+	}
+
+	putFinished(m, c.Owner(), 2)
+	m.putTask(&task.Task{
+		ID:         uuid.New(),
+		Name:       "succeeded",
+		Owner:      c.Owner(),
+		State:      task.Completed,
+		Reason:     task.ReasonCompleted,
+		FinishTime: time.Now(),
+	})
+	m.reconcile()
+
+	if tasks := ownedTasks(m, c.Owner()); len(tasks) != 0 {
+		t.Errorf("cron job kept %d finished tasks with history limits of 0", len(tasks))
+	}
+	stored, err := m.GetCronJob(c.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *stored.SuccessfulHistoryLimit != 0 || *stored.FailedHistoryLimit != 0 {
+		t.Errorf("history limits = %d and %d, want 0", *stored.SuccessfulHistoryLimit, *stored.FailedHistoryLimit)
+	}
+}
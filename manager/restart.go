@@ -0,0 +1,268 @@
+package manager
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/codding-buddha/mini-kube/common"
+	"github.com/codding-buddha/mini-kube/deployment"
+	"github.com/codding-buddha/mini-kube/node"
+	"github.com/codding-buddha/mini-kube/task"
+	"github.com/google/uuid"
+)
+
+// backoffReset is how long a task must run for its restart count to reset, so
+// its next restart happens without backing off and MaxRestarts only caps
+// crash loops.
+const backoffReset = 10 * time.Minute
+
+func (m *Manager) DoHealthChecks() {
+	for {
+		m.doHealthChecks()
+		if !m.sleep(m.HealthCheckInterval) {
+			return
+		}
+	}
+}
+
+// doHealthChecks restarts the tasks whose restart policy asks for it and whose
+// backoff ran out.
+func (m *Manager) doHealthChecks() {
+	now := time.Now()
+	for _, t := range m.GetTasks() {
+		m.maybeRestart(t.ID, now)
+	}
+}
+
+// maybeRestart restarts the task with id if its restart policy asks for it.
+// The first restart happens at once; later ones wait for a backoff that
+// doubles with every restart, during which the task is in CrashLoopBackOff.
+func (m *Manager) maybeRestart(id uuid.UUID, now time.Time) {
+	m.mu.Lock()
+	t, err := m.GetTask(id)
+	if err != nil {
+		m.mu.Unlock()
+		return
+	}
+
+	if t.RestartCount > 0 && stableRun(t, now) {
+		log.Printf("Task %v ran for %v, resetting its restart count", id, backoffReset)
+		t.RestartCount = 0
+		m.putTask(t)
+	}
+
+	reason := m.restartReason(t)
+	if reason == "" {
+		m.mu.Unlock()
+		return
+	}
+
+	if m.MaxRestarts > 0 && t.RestartCount >= m.MaxRestarts {
+		if t.Reason != task.ReasonRestartLimit {
+			log.Printf("Task %v %s, giving up after %d restarts", id, reason, t.RestartCount)
+			m.setState(t, t.State, task.ReasonRestartLimit, fmt.Sprintf("%s, gave up after %d restarts", reason, t.RestartCount))
+			t.RestartAt = time.Time{}
+			m.putTask(t)
+		}
+		m.mu.Unlock()
+		return
+	}
+
+	if t.RestartAt.IsZero() {
+		delay := m.restartDelay(t, now)
+		t.RestartAt = now.Add(delay)
+		m.putTask(t)
+		if delay > 0 {
+			log.Printf("Task %v %s, restarting it in %v", id, reason, delay)
+		}
+	}
+
+	if now.Before(t.RestartAt) {
+		m.mu.Unlock()
+		return
+	}
+
+	if t.State == task.Running {
+		m.restarted[id] = t.StartTime
+	}
+	m.mu.Unlock()
+
+	log.Printf("Task %v %s, restarting it", id, reason)
+	m.restartTask(t, reason)
+}
+
+// restartReason says why the restart policy of t asks to restart it, or
+// returns "" if it does not. The caller must hold m.mu.
+func (m *Manager) restartReason(t *task.Task) string {
+	policy := t.Restart()
+	if policy == task.RestartNever || m.isStopping(t.ID) {
+		return ""
+	}
+
+	// Standalone and deployment tasks are restarted where they are. Jobs
+	// and cron jobs count their failed tasks and start new ones instead.
+	inPlace := t.Owner == "" || deployment.Owns(t)
+	switch t.State {
+	case task.Running:
+		if t.Health.Unhealthy && !m.restarted[t.ID].Equal(t.StartTime) {
+			return t.Health.Reason
+		}
+	case task.Failed:
+		if !inPlace {
+			return ""
+		}
+		if t.ExitCode != 0 {
+			return fmt.Sprintf("failed with exit code %d", t.ExitCode)
+		}
+		return "failed"
+	case task.Completed:
+		// Only tasks that exited on their own are restarted, not stopped
+		// ones.
+		if inPlace && policy == task.RestartAlways && t.Succeeded() {
+			return "completed"
+		}
+	}
+
+	return ""
+}
+
+// stableRun reports whether the last run of t, up to now if it still runs,
+// lasted backoffReset.
+func stableRun(t *task.Task, now time.Time) bool {
+	end := now
+	if !t.FinishTime.IsZero() && t.FinishTime.After(t.StartTime) {
+		end = t.FinishTime
+	}
+
+	return !t.StartTime.IsZero() && end.Sub(t.StartTime) >= backoffReset
+}
+
+// restartDelay is how long to wait before restarting t: nothing the first
+// time or after a long run, then RestartBackoff doubling with every restart
+// up to MaxRestartBackoff.
+func (m *Manager) restartDelay(t *task.Task, now time.Time) time.Duration {
+	if t.RestartCount == 0 || stableRun(t, now) {
+		return 0
+	}
+
+	delay := m.RestartBackoff
+	for i := 1; i < t.RestartCount && delay < m.MaxRestartBackoff; i++ {
+		delay *= 2
+	}
+	if delay > m.MaxRestartBackoff {
+		delay = m.MaxRestartBackoff
+	}
+
+	return delay
+}
+
+// restartTask starts t again on its worker, or reschedules it if the worker
+// is gone or it is not placed, and records reason.
+func (m *Manager) restartTask(t *task.Task, reason string) {
+	m.mu.Lock()
+	current, err := m.GetTask(t.ID)
+	if err != nil || current.State != t.State {
+		// Another loop changed the task since it was checked.
+		m.mu.Unlock()
+		return
+	}
+	t = current
+
+	// Get the worker where the task was running
+	w, placed := m.TaskWorkerMap[t.ID]
+	if !placed {
+		// No worker holds the task, such as when one refused it, so it is
+		// queued to be placed again.
+		log.Printf("Task %v is not placed, queueing it to be placed again", t.ID)
+		m.setState(t, task.Pending, task.ReasonRestart, reason)
+		t.RecordRestart(reason, time.Now().UTC())
+		m.putTask(t)
+		queued := *t
+		queued.State = task.Scheduled
+		m.AddTask(task.TaskEvent{
+			ID:        uuid.New(),
+			State:     task.Scheduled,
+			Timestamp: time.Now(),
+			Task:      queued,
+		})
+		m.mu.Unlock()
+		return
+	}
+
+	n := m.getNode(w)
+	if n == nil || n.State == node.Lost {
+		log.Printf("Worker %v for task %v is gone, rescheduling instead of restarting", w, t.ID)
+		t.RecordRestart(reason, time.Now().UTC())
+		m.rescheduleTask(t, w)
+		m.mu.Unlock()
+		return
+	}
+
+	m.setState(t, task.Restarting, task.ReasonRestart, reason)
+	t.RecordRestart(reason, time.Now().UTC())
+	t.Health = task.Health{}
+	m.allocate(n, *t)
+	// we need to override the existing task to ensure it has
+	// the current state
+	m.putTask(t)
+	api := n.Api
+	m.mu.Unlock()
+
+	te := task.TaskEvent{
+		ID:        uuid.New(),
+		State:     task.Running,
+		Timestamp: time.Now(),
+		Task:      *t,
+	}
+
+	data, err := json.Marshal(te)
+	if err != nil {
+		log.Printf("Unable to marshall task object: %v.", t)
+	}
+
+	url := fmt.Sprintf("%s/tasks", api)
+	resp, err := m.client.Post(url, "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		log.Printf("Error connecting to %v: %v.", w, err)
+		m.restartFailed(t, err.Error())
+		return
+	}
+	defer resp.Body.Close()
+
+	d := json.NewDecoder(resp.Body)
+	if resp.StatusCode != http.StatusCreated {
+		msg := fmt.Sprintf("worker %s refused the restart with status %d", w, resp.StatusCode)
+		e := common.ErrResponse{}
+		err := d.Decode(&e)
+		if err != nil {
+			fmt.Printf("Error decoding response: %s\n", err.Error())
+		} else if e.Message != "" {
+			msg = fmt.Sprintf("worker %s refused the restart: %s", w, strings.TrimSpace(e.Message))
+		}
+		log.Printf("Response error (%d): %s", resp.StatusCode, msg)
+		m.restartFailed(t, msg)
+		return
+	}
+
+	newTask := task.Task{}
+	err = d.Decode(&newTask)
+	if err != nil {
+		fmt.Printf("Error decoding response: %s\n", err.Error())
+	}
+	log.Printf("%#v\n", t)
+}
+
+// restartFailed leaves t, whose worker did not take the restart, failed with
+// its resources released so the next health check retries it.
+func (m *Manager) restartFailed(t *task.Task, message string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.release(*t)
+	m.setState(t, task.Failed, task.ReasonRestartFailed, message)
+	m.putTask(t)
+}
@@ -0,0 +1,97 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codding-buddha/mini-kube/node"
+	"github.com/codding-buddha/mini-kube/task"
+	"github.com/google/uuid"
+)
+
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	m, err := New([]string{"worker-1"}, "roundrobin", "memory", "")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	return m
+}
+
+// placeTask stores a task in state on the manager's first node.
+func placeTask(m *Manager, state task.State, policy string) *task.Task {
+	tk := &task.Task{
+		ID:            uuid.New(),
+		Name:          "test",
+		Image:         "busybox",
+		State:         state,
+		RestartPolicy: policy,
+		StartTime:     time.Now().UTC(),
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.assignTask(m.WorkerNodes[0].Name, tk.ID)
+	m.allocate(m.WorkerNodes[0], *tk)
+	m.putTask(tk)
+	return tk
+}
+
+func TestStoppingTaskLostWithNodeIsNotRestarted(t *testing.T) {
+	m := newTestManager(t)
+	tk := placeTask(m, task.Stopping, task.RestartAlways)
+
+	n := m.WorkerNodes[0]
+	m.mu.Lock()
+	n.State = node.Lost
+	m.rescheduleTasks(n)
+	m.mu.Unlock()
+
+	m.maybeRestart(tk.ID, time.Now())
+	m.maybeRestart(tk.ID, time.Now())
+
+	stored, err := m.GetTask(tk.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.State != task.Completed || stored.Reason != task.ReasonStopped {
+		t.Errorf("state = %v (%s), want Completed (%s)", stored.State, stored.Reason, task.ReasonStopped)
+	}
+	if stored.RestartCount != 0 {
+		t.Errorf("task was restarted %d times", stored.RestartCount)
+	}
+	if n := m.pendingLen(); n != 0 {
+		t.Errorf("%d events were queued for the stopped task", n)
+	}
+}
+
+func TestRestartDelay(t *testing.T) {
+	m := newTestManager(t)
+	m.RestartBackoff = 10 * time.Second
+	m.MaxRestartBackoff = 60 * time.Second
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		restarts int
+		started  time.Time
+		want     time.Duration
+	}{
+		{"first restart is immediate", 0, now.Add(-time.Second), 0},
+		{"second waits the backoff", 1, now.Add(-time.Second), 10 * time.Second},
+		{"doubles", 2, now.Add(-time.Second), 20 * time.Second},
+		{"doubles again", 3, now.Add(-time.Second), 40 * time.Second},
+		{"capped", 4, now.Add(-time.Second), 60 * time.Second},
+		{"stays capped", 50, now.Add(-time.Second), 60 * time.Second},
+		{"long run resets", 4, now.Add(-backoffReset), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tk := &task.Task{RestartCount: tt.restarts, StartTime: tt.started}
+			if got := m.restartDelay(tk, now); got != tt.want {
+				t.Errorf("restartDelay = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
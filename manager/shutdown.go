@@ -0,0 +1,142 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/codding-buddha/mini-kube/store"
+	"github.com/codding-buddha/mini-kube/task"
+	"github.com/google/uuid"
+)
+
+// Start runs the manager's loops in the background until Stop is called.
+func (m *Manager) Start() {
+	loops := []func(){
+		m.ProcessTasks,
+		m.UpdateTasks,
+		m.UpdateNodeStats,
+		m.CheckNodes,
+		m.DoHealthChecks,
+		m.Reconcile,
+	}
+	for _, loop := range loops {
+		m.loops.Add(1)
+		go func(loop func()) {
+			defer m.loops.Done()
+			loop()
+		}(loop)
+	}
+}
+
+// Stop ends the loops started by Start and waits for the work they are
+// doing, such as posting tasks to workers, to finish. Tasks that were queued
+// but never placed are stored as pending and tasks with queued stops as
+// Stopping, so a persistent manager carries them out after a restart, and the
+// stores are closed. If ctx ends first, Stop returns
+// without closing the stores.
+func (m *Manager) Stop(ctx context.Context) error {
+	m.stopOnce.Do(func() {
+		close(m.done)
+	})
+
+	finished := make(chan struct{})
+	go func() {
+		m.loops.Wait()
+		m.dispatches.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		return fmt.Errorf("manager loops did not stop: %v", ctx.Err())
+	}
+
+	m.savePending()
+	return closeStores(m.TaskDb, m.EventDb, m.DeploymentDb, m.JobDb, m.CronJobDb)
+}
+
+// sleep waits for d and reports whether the manager is still running.
+func (m *Manager) sleep(d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-m.done:
+		return false
+	}
+}
+
+// savePending empties the pending queue, storing tasks that were submitted
+// but never stored as pending. Tasks with a queued stop are stored as
+// Stopping, so the stop is sent again once their worker reports them after a
+// restart, or as stopped if they are not placed.
+func (m *Manager) savePending() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for {
+		te, ok := m.dequeue()
+		if !ok {
+			return
+		}
+
+		switch te.State {
+		case task.Scheduled:
+			if _, err := m.GetTask(te.Task.ID); err == nil {
+				continue
+			}
+
+			t := te.Task
+			t.State = task.Pending
+			m.putTask(&t)
+		case task.Completed:
+			m.saveStop(te.Task.ID)
+		default:
+			log.Printf("Dropping queued event %v for task %v", te.ID, te.Task.ID)
+		}
+	}
+}
+
+// saveStop records a stop of the task with id that was queued but not sent.
+// The caller must hold m.mu.
+func (m *Manager) saveStop(id uuid.UUID) {
+	t, err := m.GetTask(id)
+	if err != nil || t.State == task.Completed {
+		return
+	}
+
+	t.RestartAt = time.Time{}
+	if _, placed := m.TaskWorkerMap[id]; placed && task.ValidStateTransition(t.State, task.Stopping) {
+		m.setState(t, task.Stopping, task.ReasonStopRequested, "")
+		m.putTask(t)
+		return
+	}
+
+	if m.setState(t, task.Completed, task.ReasonStopped, "stopped while not placed") {
+		t.FinishTime = time.Now().UTC()
+		m.putTask(t)
+	}
+}
+
+// closeStores closes the stores that hold open files and returns the first
+// error.
+func closeStores(stores ...store.Store) error {
+	var first error
+	for _, s := range stores {
+		c, ok := s.(io.Closer)
+		if !ok {
+			continue
+		}
+
+		err := c.Close()
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
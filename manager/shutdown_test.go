@@ -0,0 +1,45 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/codding-buddha/mini-kube/task"
+	"github.com/google/uuid"
+)
+
+// TestStopSavesQueuedStops checks that a stop still queued when the manager
+// stops is sent again after a restart.
+func TestStopSavesQueuedStops(t *testing.T) {
+	m := newTestManager(t)
+	tk := placeTask(m, task.Running, "")
+	stop := *tk
+	stop.State = task.Completed
+	m.AddTask(task.TaskEvent{ID: uuid.New(), State: task.Completed, Timestamp: time.Now(), Task: stop})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.Stop(ctx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	// A restarted manager reads the same store but knows no placements.
+	restarted := newTestManager(t)
+	restarted.TaskDb = m.TaskDb
+	stored, err := restarted.GetTask(tk.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.State != task.Stopping {
+		t.Fatalf("state after restart = %v, want Stopping", stored.State)
+	}
+
+	// The worker still runs the task when the manager hears from it again.
+	reported := *tk
+	restarted.updateTask("worker-1", &reported)
+	te, ok := restarted.dequeue()
+	if !ok || te.State != task.Completed || te.Task.ID != tk.ID {
+		t.Errorf("queued %+v after the worker reported the task, want its stop", te)
+	}
+}
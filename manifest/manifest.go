@@ -0,0 +1,192 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Kinds of resource a manifest can describe.
+const (
+	Task       = "Task"
+	Deployment = "Deployment"
+	Job        = "Job"
+	CronJob    = "CronJob"
+)
+
+// Manifest declares a resource by kind and name. Spec holds the fields of the
+// resource as JSON; its keys match the resource's fields case-insensitively,
+// so both "replicas" and "Replicas" work.
+type Manifest struct {
+	Kind     string
+	Metadata Metadata
+	Spec     json.RawMessage
+}
+
+type Metadata struct {
+	Name string
+}
+
+// Parse reads one or more manifests from data, which may be JSON or YAML
+// with documents separated by "---".
+func Parse(data []byte) ([]Manifest, error) {
+	var manifests []Manifest
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for i := 1; ; i++ {
+		var doc interface{}
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %v", i, err)
+		}
+
+		if doc == nil {
+			continue
+		}
+
+		m, err := decode(doc)
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %v", i, err)
+		}
+		manifests = append(manifests, m)
+	}
+
+	if len(manifests) == 0 {
+		return nil, errors.New("no manifests found")
+	}
+
+	return manifests, nil
+}
+
+// decode converts a YAML document to a Manifest by way of JSON, so specs
+// decode into resource types with their JSON rules.
+func decode(doc interface{}) (Manifest, error) {
+	buf, err := json.Marshal(doc)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	var m Manifest
+	d := json.NewDecoder(bytes.NewReader(buf))
+	d.DisallowUnknownFields()
+	err = d.Decode(&m)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	switch m.Kind {
+	case Task, Deployment, Job, CronJob:
+	default:
+		return Manifest{}, fmt.Errorf("unknown kind %q", m.Kind)
+	}
+
+	if m.Metadata.Name == "" {
+		return Manifest{}, fmt.Errorf("%s manifest has no metadata.name", m.Kind)
+	}
+
+	return m, nil
+}
+
+// DecodeSpec decodes the spec of m into v, rejecting unknown fields.
+func (m *Manifest) DecodeSpec(v interface{}) error {
+	if len(m.Spec) == 0 {
+		return fmt.Errorf("%s %s has no spec", m.Kind, m.Metadata.Name)
+	}
+
+	d := json.NewDecoder(bytes.NewReader(m.Spec))
+	d.DisallowUnknownFields()
+	err := d.Decode(v)
+	if err != nil {
+		return fmt.Errorf("invalid spec for %s %s: %v", m.Kind, m.Metadata.Name, err)
+	}
+
+	return nil
+}
+
+// Diff lists the differences between the current and desired form of a
+// resource as lines of "path: old -> new". Both values are compared by their
+// JSON encoding. A nil current stands for the zero value of desired's type,
+// so a new resource lists the fields it sets.
+func Diff(current, desired interface{}) ([]string, error) {
+	if current == nil {
+		current = reflect.Zero(reflect.TypeOf(desired)).Interface()
+	}
+
+	var a, b interface{}
+	err := roundTrip(current, &a)
+	if err != nil {
+		return nil, err
+	}
+
+	err = roundTrip(desired, &b)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	diff("", a, b, &lines)
+	return lines, nil
+}
+
+func roundTrip(v interface{}, out *interface{}) error {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(buf, out)
+}
+
+func diff(path string, a, b interface{}, lines *[]string) {
+	am, aok := a.(map[string]interface{})
+	bm, bok := b.(map[string]interface{})
+	if aok && bok {
+		for _, k := range sortedKeys(am, bm) {
+			diff(join(path, k), am[k], bm[k], lines)
+		}
+		return
+	}
+
+	as, bs := encode(a), encode(b)
+	if as != bs {
+		*lines = append(*lines, fmt.Sprintf("%s: %s -> %s", path, as, bs))
+	}
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
+
+func encode(v interface{}) string {
+	buf, _ := json.Marshal(v)
+	return string(buf)
+}
+
+// sortedKeys returns the keys of a and b in order, each once.
+func sortedKeys(a, b map[string]interface{}) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, m := range []map[string]interface{}{a, b} {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,93 @@
+package manifest
+
+import (
+	"reflect"
+	"testing"
+)
+
+type spec struct {
+	Image    string
+	Replicas int
+	Env      []string
+	Limits   map[string]int
+	Strategy struct {
+		MaxSurge int
+	}
+}
+
+func TestDiff(t *testing.T) {
+	base := spec{Image: "nginx:1", Replicas: 2, Env: []string{"A=1"}, Limits: map[string]int{"cpu": 1}}
+	base.Strategy.MaxSurge = 1
+
+	tests := []struct {
+		name    string
+		current interface{}
+		desired func() spec
+		want    []string
+	}{
+		{
+			name:    "unchanged",
+			current: base,
+			desired: func() spec { return base },
+		},
+		{
+			name:    "changed fields in order",
+			current: base,
+			desired: func() spec {
+				s := base
+				s.Replicas = 3
+				s.Image = "nginx:2"
+				return s
+			},
+			want: []string{`Image: "nginx:1" -> "nginx:2"`, "Replicas: 2 -> 3"},
+		},
+		{
+			name:    "nested fields",
+			current: base,
+			desired: func() spec {
+				s := base
+				s.Strategy.MaxSurge = 2
+				return s
+			},
+			want: []string{"Strategy.MaxSurge: 1 -> 2"},
+		},
+		{
+			name:    "map keys added and changed",
+			current: base,
+			desired: func() spec {
+				s := base
+				s.Limits = map[string]int{"cpu": 2, "memory": 64}
+				return s
+			},
+			want: []string{"Limits.cpu: 1 -> 2", "Limits.memory: null -> 64"},
+		},
+		{
+			name:    "lists compare whole",
+			current: base,
+			desired: func() spec {
+				s := base
+				s.Env = []string{"A=1", "B=2"}
+				return s
+			},
+			want: []string{`Env: ["A=1"] -> ["A=1","B=2"]`},
+		},
+		{
+			name:    "nil current lists the fields set",
+			current: nil,
+			desired: func() spec { return spec{Image: "nginx:1"} },
+			want:    []string{`Image: "" -> "nginx:1"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Diff(tt.current, tt.desired())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,13 +1,158 @@
 package node
 
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/codding-buddha/mini-kube/stats"
+)
+
+// statsClient fetches worker stats, timing out workers that do not answer
+// so the stats loop moves on to the others.
+var statsClient = &http.Client{Timeout: 10 * time.Second}
+
+type State int
+
+const (
+	Ready State = iota
+	NotReady
+	Lost
+)
+
+func (s State) String() string {
+	switch s {
+	case Ready:
+		return "Ready"
+	case NotReady:
+		return "NotReady"
+	case Lost:
+		return "Lost"
+	default:
+		return "Unknown"
+	}
+}
+
+// Registration is posted by a worker to the manager to join the cluster.
+type Registration struct {
+	Name    string
+	Address string
+	Stats   stats.Stats
+}
+
 type Node struct {
 	Name            string
 	Ip              string
+	Api             string
 	Cores           int
-	Memory          int
+	Memory          int64
 	Role            string
-	MemoryAllocated int
-	Disk            int
-	DiskAllocated   int
+	MemoryAllocated int64
+	Disk            int64
+	DiskAllocated   int64
 	TaskCount       int
+	Stats           stats.Stats
+	State           State
+	LastHeartbeat   time.Time
+	// Unschedulable keeps new tasks off the node. It is set by cordoning the
+	// node and cleared by uncordoning it.
+	Unschedulable bool
+	// Draining moves the node's tasks to other nodes.
+	Draining bool
+}
+
+func NewNode(name string, api string, role string) *Node {
+	return &Node{
+		Name:          name,
+		Api:           api,
+		Role:          role,
+		State:         Ready,
+		LastHeartbeat: time.Now(),
+	}
+}
+
+// GetStats fetches the worker's /stats endpoint and refreshes the node's capacity.
+func (n *Node) GetStats() (*stats.Stats, error) {
+	s, err := FetchStats(n.Api)
+	if err != nil {
+		return nil, err
+	}
+
+	n.UpdateStats(*s)
+	return s, nil
+}
+
+// FetchStats fetches the /stats endpoint of the worker serving api without
+// touching any node, so it can be called without holding the node's owner lock.
+func FetchStats(api string) (*stats.Stats, error) {
+	url := fmt.Sprintf("%s/stats", api)
+	resp, err := statsClient.Get(url)
+	if err != nil {
+		log.Printf("Unable to connect to %v: %v", api, err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error retrieving stats from %v: status %d", api, resp.StatusCode)
+	}
+
+	var s stats.Stats
+	err = json.NewDecoder(resp.Body).Decode(&s)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding stats from %s: %v", api, err)
+	}
+
+	return &s, nil
+}
+
+// UpdateStats records stats reported by the worker. It says nothing about
+// whether the worker is alive; only RecordHeartbeat does.
+func (n *Node) UpdateStats(s stats.Stats) {
+	n.Stats = s
+	if s.MemStats != nil {
+		n.Memory = int64(s.MemToTalKb())
+	}
+
+	if s.DiskStats != nil {
+		n.Disk = int64(s.DiskTotal())
+	}
+
+	if s.CpuCount > 0 {
+		n.Cores = s.CpuCount
+	}
+}
+
+// RecordHeartbeat records stats the worker sent with a heartbeat or its
+// registration and marks the node as alive.
+func (n *Node) RecordHeartbeat(s stats.Stats) {
+	n.UpdateStats(s)
+	n.LastHeartbeat = time.Now()
+	n.State = Ready
+}
+
+// Allocate debits the resources requested by a task placed on the node.
+func (n *Node) Allocate(memory int64, disk int64) {
+	n.MemoryAllocated += memory
+	n.DiskAllocated += disk
+	n.TaskCount++
+}
+
+// Release credits back the resources of a task that finished on the node.
+func (n *Node) Release(memory int64, disk int64) {
+	n.MemoryAllocated -= memory
+	if n.MemoryAllocated < 0 {
+		n.MemoryAllocated = 0
+	}
+
+	n.DiskAllocated -= disk
+	if n.DiskAllocated < 0 {
+		n.DiskAllocated = 0
+	}
+
+	if n.TaskCount > 0 {
+		n.TaskCount--
+	}
 }
@@ -0,0 +1,60 @@
+package scheduler
+
+import (
+	"math"
+
+	"github.com/codding-buddha/mini-kube/node"
+	"github.com/codding-buddha/mini-kube/task"
+)
+
+// LIEB is the base of the E-PVM cost function, the value that makes the
+// marginal cost of a resource grow exponentially with its utilization.
+const LIEB = 1.53960071783900203869
+
+// tasksPerCore approximates how many tasks a core can host when a node's CPU
+// usage is estimated from its task count.
+const tasksPerCore = 4.0
+
+// Epvm implements the Enhanced Parallel Virtual Machine placement algorithm:
+// a task goes to the node where it adds the least marginal cost over all resources.
+type Epvm struct {
+	Name string
+}
+
+func (e *Epvm) SelectCandidateNodes(t task.Task, nodes []*node.Node) []*node.Node {
+	return selectCandidateNodes(t, nodes)
+}
+
+func (e *Epvm) Score(t task.Task, nodes []*node.Node) map[string]float64 {
+	scores := make(map[string]float64)
+	for _, n := range nodes {
+		var cost float64
+		if n.Memory > 0 {
+			cost += marginalCost(float64(n.MemoryAllocated), float64(t.Memory), float64(n.Memory))
+		}
+
+		if n.Disk > 0 {
+			cost += marginalCost(float64(n.DiskAllocated), float64(t.Disk), float64(n.Disk))
+		}
+
+		cores := n.Cores
+		if cores == 0 {
+			cores = 1
+		}
+		cost += marginalCost(float64(n.TaskCount), 1, float64(cores)*tasksPerCore)
+
+		scores[n.Name] = cost
+	}
+
+	return scores
+}
+
+func (e *Epvm) Pick(scores map[string]float64, candidates []*node.Node) *node.Node {
+	return pickLowest(scores, candidates)
+}
+
+// marginalCost returns the increase of LIEB^utilization when demand is added
+// to a resource with the given allocation and capacity.
+func marginalCost(allocated float64, demand float64, capacity float64) float64 {
+	return math.Pow(LIEB, (allocated+demand)/capacity) - math.Pow(LIEB, allocated/capacity)
+}
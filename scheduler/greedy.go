@@ -0,0 +1,49 @@
+package scheduler
+
+import (
+	"github.com/codding-buddha/mini-kube/node"
+	"github.com/codding-buddha/mini-kube/task"
+)
+
+// Greedy places a task on the least loaded candidate node.
+type Greedy struct {
+	Name string
+}
+
+func (g *Greedy) SelectCandidateNodes(t task.Task, nodes []*node.Node) []*node.Node {
+	return selectCandidateNodes(t, nodes)
+}
+
+// Score is the mean fraction of each resource that would be used once the
+// task is placed: memory and disk where the node reported them, and its task
+// slots, estimated from its cores as E-PVM does. Every score is on that one
+// scale, so nodes are ordered by load whichever resources they report.
+func (g *Greedy) Score(t task.Task, nodes []*node.Node) map[string]float64 {
+	scores := make(map[string]float64)
+	for _, n := range nodes {
+		cores := n.Cores
+		if cores == 0 {
+			cores = 1
+		}
+		load := float64(n.TaskCount+1) / (float64(cores) * tasksPerCore)
+		resources := 1
+
+		if n.Memory > 0 {
+			load += float64(n.MemoryAllocated+t.Memory) / float64(n.Memory)
+			resources++
+		}
+
+		if n.Disk > 0 {
+			load += float64(n.DiskAllocated+t.Disk) / float64(n.Disk)
+			resources++
+		}
+
+		scores[n.Name] = load / float64(resources)
+	}
+
+	return scores
+}
+
+func (g *Greedy) Pick(scores map[string]float64, candidates []*node.Node) *node.Node {
+	return pickLowest(scores, candidates)
+}
@@ -0,0 +1,39 @@
+package scheduler
+
+import (
+	"github.com/codding-buddha/mini-kube/node"
+	"github.com/codding-buddha/mini-kube/task"
+)
+
+// RoundRobin places tasks on candidate nodes in turn, ignoring their load.
+type RoundRobin struct {
+	Name       string
+	LastWorker int
+}
+
+func (r *RoundRobin) SelectCandidateNodes(t task.Task, nodes []*node.Node) []*node.Node {
+	return selectCandidateNodes(t, nodes)
+}
+
+func (r *RoundRobin) Score(t task.Task, nodes []*node.Node) map[string]float64 {
+	scores := make(map[string]float64)
+	if len(nodes) == 0 {
+		return scores
+	}
+
+	next := (r.LastWorker + 1) % len(nodes)
+	r.LastWorker = next
+	for idx, n := range nodes {
+		if idx == next {
+			scores[n.Name] = 0.1
+		} else {
+			scores[n.Name] = 1.0
+		}
+	}
+
+	return scores
+}
+
+func (r *RoundRobin) Pick(scores map[string]float64, candidates []*node.Node) *node.Node {
+	return pickLowest(scores, candidates)
+}
@@ -1,7 +1,67 @@
 package scheduler
 
+import (
+	"github.com/codding-buddha/mini-kube/node"
+	"github.com/codding-buddha/mini-kube/task"
+)
+
 type Scheduler interface {
-	SelectCandidateNodes()
-	Score()
-	Pick()
+	// SelectCandidateNodes filters out nodes that cannot accommodate the task.
+	SelectCandidateNodes(t task.Task, nodes []*node.Node) []*node.Node
+	// Score assigns a score to every candidate node keyed by node name, lower is better.
+	Score(t task.Task, nodes []*node.Node) map[string]float64
+	// Pick returns the candidate with the best score.
+	Pick(scores map[string]float64, candidates []*node.Node) *node.Node
+}
+
+// New returns the scheduler registered under name, defaulting to round-robin.
+func New(name string) Scheduler {
+	switch name {
+	case "greedy":
+		return &Greedy{Name: "greedy"}
+	case "epvm":
+		return &Epvm{Name: "epvm"}
+	default:
+		return &RoundRobin{Name: "roundrobin", LastWorker: -1}
+	}
+}
+
+// selectCandidateNodes returns nodes with enough free memory and disk for t.
+// Nodes that have not reported their capacity yet are treated as unconstrained.
+func selectCandidateNodes(t task.Task, nodes []*node.Node) []*node.Node {
+	var candidates []*node.Node
+	for _, n := range nodes {
+		if checkMemory(t, n) && checkDisk(t, n) {
+			candidates = append(candidates, n)
+		}
+	}
+
+	return candidates
+}
+
+func checkMemory(t task.Task, n *node.Node) bool {
+	return n.Memory == 0 || n.Memory-n.MemoryAllocated >= t.Memory
+}
+
+func checkDisk(t task.Task, n *node.Node) bool {
+	return n.Disk == 0 || n.Disk-n.DiskAllocated >= t.Disk
+}
+
+// pickLowest returns the candidate with the lowest score.
+func pickLowest(scores map[string]float64, candidates []*node.Node) *node.Node {
+	var best *node.Node
+	var lowest float64
+	for _, n := range candidates {
+		score, ok := scores[n.Name]
+		if !ok {
+			continue
+		}
+
+		if best == nil || score < lowest {
+			best = n
+			lowest = score
+		}
+	}
+
+	return best
 }
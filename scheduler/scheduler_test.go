@@ -0,0 +1,187 @@
+package scheduler
+
+import (
+	"math"
+	"testing"
+
+	"github.com/codding-buddha/mini-kube/node"
+	"github.com/codding-buddha/mini-kube/task"
+)
+
+func names(nodes []*node.Node) []string {
+	var out []string
+	for _, n := range nodes {
+		out = append(out, n.Name)
+	}
+
+	return out
+}
+
+func TestSelectCandidateNodes(t *testing.T) {
+	nodes := []*node.Node{
+		{Name: "unreported"},
+		{Name: "full-memory", Memory: 1000, MemoryAllocated: 950},
+		{Name: "full-disk", Disk: 1000, DiskAllocated: 950},
+		{Name: "exact-fit", Memory: 1000, MemoryAllocated: 900, Disk: 1000, DiskAllocated: 900},
+		{Name: "roomy", Memory: 4000, Disk: 4000},
+	}
+
+	got := names(selectCandidateNodes(task.Task{Memory: 100, Disk: 100}, nodes))
+	want := []string{"unreported", "exact-fit", "roomy"}
+	if len(got) != len(want) {
+		t.Fatalf("candidates = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("candidates = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestPickLowest(t *testing.T) {
+	a, b, c := &node.Node{Name: "a"}, &node.Node{Name: "b"}, &node.Node{Name: "c"}
+	tests := []struct {
+		name   string
+		scores map[string]float64
+		want   *node.Node
+	}{
+		{"lowest wins", map[string]float64{"a": 0.5, "b": 0.2, "c": 0.9}, b},
+		{"ties go to the first candidate", map[string]float64{"a": 0.3, "b": 0.3, "c": 0.3}, a},
+		{"unscored candidates are skipped", map[string]float64{"c": 0.9}, c},
+		{"nothing scored", map[string]float64{}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pickLowest(tt.scores, []*node.Node{a, b, c}); got != tt.want {
+				t.Errorf("picked %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundRobinTakesNodesInTurn(t *testing.T) {
+	s := New("roundrobin")
+	nodes := []*node.Node{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+
+	var got []string
+	for i := 0; i < 5; i++ {
+		candidates := s.SelectCandidateNodes(task.Task{}, nodes)
+		got = append(got, s.Pick(s.Score(task.Task{}, candidates), candidates).Name)
+	}
+
+	want := []string{"a", "b", "c", "a", "b"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("picks = %v, want %v", got, want)
+		}
+	}
+
+	// Fewer candidates than the last pick wraps around instead of
+	// skipping every node.
+	if n := s.Pick(s.Score(task.Task{}, nodes[:1]), nodes[:1]); n == nil || n.Name != "a" {
+		t.Errorf("pick from one candidate = %v, want a", n)
+	}
+}
+
+func TestGreedyScore(t *testing.T) {
+	tests := []struct {
+		name string
+		node *node.Node
+		task task.Task
+		want float64
+	}{
+		{"task slots only", &node.Node{Name: "n", Cores: 2, TaskCount: 3}, task.Task{}, 0.5},
+		{"no cores reported counts one", &node.Node{Name: "n", TaskCount: 1}, task.Task{}, 0.5},
+		{"memory and disk averaged in", &node.Node{Name: "n", Cores: 1, Memory: 1000, MemoryAllocated: 400, Disk: 1000}, task.Task{Memory: 100, Disk: 250}, (0.25 + 0.5 + 0.25) / 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := New("greedy").Score(tt.task, []*node.Node{tt.node})["n"]
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("score = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchedulersPickLeastLoaded(t *testing.T) {
+	tests := []struct {
+		name  string
+		nodes []*node.Node
+		task  task.Task
+		want  string
+	}{
+		{
+			name:  "fewer tasks",
+			nodes: []*node.Node{{Name: "busy", Cores: 1, TaskCount: 3}, {Name: "idle", Cores: 1}},
+			want:  "idle",
+		},
+		{
+			name:  "more cores",
+			nodes: []*node.Node{{Name: "small", Cores: 1, TaskCount: 2}, {Name: "large", Cores: 8, TaskCount: 2}},
+			want:  "large",
+		},
+		{
+			name: "less memory used",
+			nodes: []*node.Node{
+				{Name: "full", Cores: 4, Memory: 1000, MemoryAllocated: 800},
+				{Name: "empty", Cores: 4, Memory: 1000},
+			},
+			task: task.Task{Memory: 100},
+			want: "empty",
+		},
+		{
+			name: "less disk used",
+			nodes: []*node.Node{
+				{Name: "full", Cores: 4, Disk: 1000, DiskAllocated: 800},
+				{Name: "empty", Cores: 4, Disk: 1000},
+			},
+			task: task.Task{Disk: 100},
+			want: "empty",
+		},
+		{
+			name: "nodes that cannot fit the task are skipped",
+			nodes: []*node.Node{
+				{Name: "idle", Cores: 8, Memory: 1000, MemoryAllocated: 950},
+				{Name: "busy", Cores: 1, TaskCount: 2, Memory: 1000},
+			},
+			task: task.Task{Memory: 100},
+			want: "busy",
+		},
+	}
+
+	for _, name := range []string{"greedy", "epvm"} {
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				s := New(name)
+				candidates := s.SelectCandidateNodes(tt.task, tt.nodes)
+				n := s.Pick(s.Score(tt.task, candidates), candidates)
+				if n == nil || n.Name != tt.want {
+					t.Errorf("picked %v, want %s", n, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestMarginalCost(t *testing.T) {
+	if c := marginalCost(500, 0, 1000); c != 0 {
+		t.Errorf("cost of no demand = %v, want 0", c)
+	}
+
+	// The same demand costs more the fuller the resource already is.
+	prev := 0.0
+	for _, allocated := range []float64{0, 250, 500, 750} {
+		c := marginalCost(allocated, 100, 1000)
+		if c <= prev {
+			t.Errorf("cost at %v allocated = %v, not above %v", allocated, c, prev)
+		}
+		prev = c
+	}
+
+	if c, want := marginalCost(0, 1000, 1000), LIEB-1; math.Abs(c-want) > 1e-9 {
+		t.Errorf("cost of filling an empty resource = %v, want %v", c, want)
+	}
+}
@@ -1,4 +1,4 @@
-package worker
+package stats
 
 import (
 	"log"
@@ -13,6 +13,7 @@ type Stats struct {
 	MemStats  *mem.VirtualMemoryStat
 	DiskStats *disk.UsageStat
 	LoadStats *load.AvgStat
+	CpuCount  int
 	TaskCount int
 }
 
@@ -60,9 +61,20 @@ func GetStats() *Stats {
 		MemStats:  GetMemoryInfo(),
 		DiskStats: GetDiskInfo(),
 		LoadStats: GetLoadAvg(),
+		CpuCount:  GetCpuCount(),
 	}
 }
 
+func GetCpuCount() int {
+	count, err := cpu.Counts(true)
+	if err != nil {
+		log.Printf("Error reading cpu count")
+		return 0
+	}
+
+	return count
+}
+
 func GetMemoryInfo() *mem.VirtualMemoryStat {
 	memstats, err := mem.VirtualMemory()
 	if err != nil {
@@ -0,0 +1,347 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/boltdb/bolt"
+	"github.com/codding-buddha/mini-kube/cronjob"
+	"github.com/codding-buddha/mini-kube/deployment"
+	"github.com/codding-buddha/mini-kube/job"
+	"github.com/codding-buddha/mini-kube/task"
+)
+
+// boltStore keeps JSON encoded values in a single bucket of a bolt database.
+type boltStore struct {
+	Db     *bolt.DB
+	Bucket string
+}
+
+func newBoltStore(file string, mode os.FileMode, bucket string) (*boltStore, error) {
+	db, err := bolt.Open(file, mode, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open %v: %v", file, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to create bucket %v: %v", bucket, err)
+	}
+
+	return &boltStore{Db: db, Bucket: bucket}, nil
+}
+
+func (b *boltStore) Close() error {
+	return b.Db.Close()
+}
+
+func (b *boltStore) Count() (int, error) {
+	count := 0
+	err := b.Db.View(func(tx *bolt.Tx) error {
+		count = tx.Bucket([]byte(b.Bucket)).Stats().KeyN
+		return nil
+	})
+
+	return count, err
+}
+
+func (b *boltStore) Delete(key string) error {
+	return b.Db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(b.Bucket)).Delete([]byte(key))
+	})
+}
+
+func (b *boltStore) put(key string, value interface{}) error {
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return b.Db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(b.Bucket)).Put([]byte(key), buf)
+	})
+}
+
+func (b *boltStore) get(key string, value interface{}) error {
+	return b.Db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket([]byte(b.Bucket)).Get([]byte(key))
+		if v == nil {
+			return fmt.Errorf("key %s does not exist in %s", key, b.Bucket)
+		}
+
+		return json.Unmarshal(v, value)
+	})
+}
+
+func (b *boltStore) forEach(fn func(v []byte) error) error {
+	return b.Db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(b.Bucket)).ForEach(func(k, v []byte) error {
+			return fn(v)
+		})
+	})
+}
+
+// TaskStore persists tasks in a bolt database file.
+type TaskStore struct {
+	*boltStore
+}
+
+func NewTaskStore(file string, mode os.FileMode, bucket string) (*TaskStore, error) {
+	b, err := newBoltStore(file, mode, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TaskStore{b}, nil
+}
+
+func (s *TaskStore) Put(key string, value interface{}) error {
+	t, ok := value.(*task.Task)
+	if !ok {
+		return fmt.Errorf("value %v is not a task.Task type", value)
+	}
+
+	return s.put(key, t)
+}
+
+func (s *TaskStore) Get(key string) (interface{}, error) {
+	var t task.Task
+	err := s.get(key, &t)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+func (s *TaskStore) List() (interface{}, error) {
+	tasks := []*task.Task{}
+	err := s.forEach(func(v []byte) error {
+		var t task.Task
+		err := json.Unmarshal(v, &t)
+		if err != nil {
+			return err
+		}
+
+		tasks = append(tasks, &t)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tasks, nil
+}
+
+// EventStore persists task events in a bolt database file.
+type EventStore struct {
+	*boltStore
+}
+
+func NewEventStore(file string, mode os.FileMode, bucket string) (*EventStore, error) {
+	b, err := newBoltStore(file, mode, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EventStore{b}, nil
+}
+
+func (s *EventStore) Put(key string, value interface{}) error {
+	e, ok := value.(*task.Event)
+	if !ok {
+		return fmt.Errorf("value %v is not a task.Event type", value)
+	}
+
+	return s.put(key, e)
+}
+
+func (s *EventStore) Get(key string) (interface{}, error) {
+	var e task.Event
+	err := s.get(key, &e)
+	if err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+func (s *EventStore) List() (interface{}, error) {
+	events := []*task.Event{}
+	err := s.forEach(func(v []byte) error {
+		var e task.Event
+		err := json.Unmarshal(v, &e)
+		if err != nil {
+			return err
+		}
+
+		events = append(events, &e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// DeploymentStore persists deployments in a bolt database file.
+type DeploymentStore struct {
+	*boltStore
+}
+
+func NewDeploymentStore(file string, mode os.FileMode, bucket string) (*DeploymentStore, error) {
+	b, err := newBoltStore(file, mode, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DeploymentStore{b}, nil
+}
+
+func (s *DeploymentStore) Put(key string, value interface{}) error {
+	d, ok := value.(*deployment.Deployment)
+	if !ok {
+		return fmt.Errorf("value %v is not a deployment.Deployment type", value)
+	}
+
+	return s.put(key, d)
+}
+
+func (s *DeploymentStore) Get(key string) (interface{}, error) {
+	var d deployment.Deployment
+	err := s.get(key, &d)
+	if err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+func (s *DeploymentStore) List() (interface{}, error) {
+	deployments := []*deployment.Deployment{}
+	err := s.forEach(func(v []byte) error {
+		var d deployment.Deployment
+		err := json.Unmarshal(v, &d)
+		if err != nil {
+			return err
+		}
+
+		deployments = append(deployments, &d)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deployments, nil
+}
+
+// JobStore persists jobs in a bolt database file.
+type JobStore struct {
+	*boltStore
+}
+
+func NewJobStore(file string, mode os.FileMode, bucket string) (*JobStore, error) {
+	b, err := newBoltStore(file, mode, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	return &JobStore{b}, nil
+}
+
+func (s *JobStore) Put(key string, value interface{}) error {
+	j, ok := value.(*job.Job)
+	if !ok {
+		return fmt.Errorf("value %v is not a job.Job type", value)
+	}
+
+	return s.put(key, j)
+}
+
+func (s *JobStore) Get(key string) (interface{}, error) {
+	var j job.Job
+	err := s.get(key, &j)
+	if err != nil {
+		return nil, err
+	}
+
+	return &j, nil
+}
+
+func (s *JobStore) List() (interface{}, error) {
+	jobs := []*job.Job{}
+	err := s.forEach(func(v []byte) error {
+		var j job.Job
+		err := json.Unmarshal(v, &j)
+		if err != nil {
+			return err
+		}
+
+		jobs = append(jobs, &j)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// CronJobStore persists cron jobs in a bolt database file.
+type CronJobStore struct {
+	*boltStore
+}
+
+func NewCronJobStore(file string, mode os.FileMode, bucket string) (*CronJobStore, error) {
+	b, err := newBoltStore(file, mode, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CronJobStore{b}, nil
+}
+
+func (s *CronJobStore) Put(key string, value interface{}) error {
+	c, ok := value.(*cronjob.CronJob)
+	if !ok {
+		return fmt.Errorf("value %v is not a cronjob.CronJob type", value)
+	}
+
+	return s.put(key, c)
+}
+
+func (s *CronJobStore) Get(key string) (interface{}, error) {
+	var c cronjob.CronJob
+	err := s.get(key, &c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+func (s *CronJobStore) List() (interface{}, error) {
+	cronJobs := []*cronjob.CronJob{}
+	err := s.forEach(func(v []byte) error {
+		var c cronjob.CronJob
+		err := json.Unmarshal(v, &c)
+		if err != nil {
+			return err
+		}
+
+		cronJobs = append(cronJobs, &c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return cronJobs, nil
+}
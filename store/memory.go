@@ -0,0 +1,342 @@
+package store
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/codding-buddha/mini-kube/cronjob"
+	"github.com/codding-buddha/mini-kube/deployment"
+	"github.com/codding-buddha/mini-kube/job"
+	"github.com/codding-buddha/mini-kube/task"
+)
+
+type InMemoryTaskStore struct {
+	mu sync.RWMutex
+	Db map[string]*task.Task
+}
+
+func NewInMemoryTaskStore() *InMemoryTaskStore {
+	return &InMemoryTaskStore{
+		Db: make(map[string]*task.Task),
+	}
+}
+
+func (i *InMemoryTaskStore) Put(key string, value interface{}) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	t, ok := value.(*task.Task)
+	if !ok {
+		return fmt.Errorf("value %v is not a task.Task type", value)
+	}
+
+	// Store a copy so callers cannot change the stored task without a Put.
+	c := *t
+	i.Db[key] = &c
+	return nil
+}
+
+func (i *InMemoryTaskStore) Get(key string) (interface{}, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	t, ok := i.Db[key]
+	if !ok {
+		return nil, fmt.Errorf("task with key %s does not exist", key)
+	}
+
+	c := *t
+	return &c, nil
+}
+
+func (i *InMemoryTaskStore) List() (interface{}, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	tasks := []*task.Task{}
+	for _, t := range i.Db {
+		c := *t
+		tasks = append(tasks, &c)
+	}
+
+	return tasks, nil
+}
+
+func (i *InMemoryTaskStore) Count() (int, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return len(i.Db), nil
+}
+
+func (i *InMemoryTaskStore) Delete(key string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	delete(i.Db, key)
+	return nil
+}
+
+type InMemoryTaskEventStore struct {
+	mu sync.RWMutex
+	Db map[string]*task.Event
+}
+
+func NewInMemoryTaskEventStore() *InMemoryTaskEventStore {
+	return &InMemoryTaskEventStore{
+		Db: make(map[string]*task.Event),
+	}
+}
+
+func (i *InMemoryTaskEventStore) Put(key string, value interface{}) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	e, ok := value.(*task.Event)
+	if !ok {
+		return fmt.Errorf("value %v is not a task.Event type", value)
+	}
+
+	c := *e
+	i.Db[key] = &c
+	return nil
+}
+
+func (i *InMemoryTaskEventStore) Get(key string) (interface{}, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	e, ok := i.Db[key]
+	if !ok {
+		return nil, fmt.Errorf("task event with key %s does not exist", key)
+	}
+
+	c := *e
+	return &c, nil
+}
+
+func (i *InMemoryTaskEventStore) List() (interface{}, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	events := []*task.Event{}
+	for _, e := range i.Db {
+		c := *e
+		events = append(events, &c)
+	}
+
+	return events, nil
+}
+
+func (i *InMemoryTaskEventStore) Count() (int, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return len(i.Db), nil
+}
+
+func (i *InMemoryTaskEventStore) Delete(key string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	delete(i.Db, key)
+	return nil
+}
+
+type InMemoryDeploymentStore struct {
+	mu sync.RWMutex
+	Db map[string]*deployment.Deployment
+}
+
+func NewInMemoryDeploymentStore() *InMemoryDeploymentStore {
+	return &InMemoryDeploymentStore{
+		Db: make(map[string]*deployment.Deployment),
+	}
+}
+
+func (i *InMemoryDeploymentStore) Put(key string, value interface{}) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	d, ok := value.(*deployment.Deployment)
+	if !ok {
+		return fmt.Errorf("value %v is not a deployment.Deployment type", value)
+	}
+
+	c := *d
+	i.Db[key] = &c
+	return nil
+}
+
+func (i *InMemoryDeploymentStore) Get(key string) (interface{}, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	d, ok := i.Db[key]
+	if !ok {
+		return nil, fmt.Errorf("deployment with key %s does not exist", key)
+	}
+
+	c := *d
+	return &c, nil
+}
+
+func (i *InMemoryDeploymentStore) List() (interface{}, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	deployments := []*deployment.Deployment{}
+	for _, d := range i.Db {
+		c := *d
+		deployments = append(deployments, &c)
+	}
+
+	return deployments, nil
+}
+
+func (i *InMemoryDeploymentStore) Count() (int, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return len(i.Db), nil
+}
+
+func (i *InMemoryDeploymentStore) Delete(key string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	delete(i.Db, key)
+	return nil
+}
+
+type InMemoryJobStore struct {
+	mu sync.RWMutex
+	Db map[string]*job.Job
+}
+
+func NewInMemoryJobStore() *InMemoryJobStore {
+	return &InMemoryJobStore{
+		Db: make(map[string]*job.Job),
+	}
+}
+
+func (i *InMemoryJobStore) Put(key string, value interface{}) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	j, ok := value.(*job.Job)
+	if !ok {
+		return fmt.Errorf("value %v is not a job.Job type", value)
+	}
+
+	c := *j
+	i.Db[key] = &c
+	return nil
+}
+
+func (i *InMemoryJobStore) Get(key string) (interface{}, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	j, ok := i.Db[key]
+	if !ok {
+		return nil, fmt.Errorf("job with key %s does not exist", key)
+	}
+
+	c := *j
+	return &c, nil
+}
+
+func (i *InMemoryJobStore) List() (interface{}, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	jobs := []*job.Job{}
+	for _, j := range i.Db {
+		c := *j
+		jobs = append(jobs, &c)
+	}
+
+	return jobs, nil
+}
+
+func (i *InMemoryJobStore) Count() (int, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return len(i.Db), nil
+}
+
+func (i *InMemoryJobStore) Delete(key string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	delete(i.Db, key)
+	return nil
+}
+
+type InMemoryCronJobStore struct {
+	mu sync.RWMutex
+	Db map[string]*cronjob.CronJob
+}
+
+func NewInMemoryCronJobStore() *InMemoryCronJobStore {
+	return &InMemoryCronJobStore{
+		Db: make(map[string]*cronjob.CronJob),
+	}
+}
+
+func (i *InMemoryCronJobStore) Put(key string, value interface{}) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	c, ok := value.(*cronjob.CronJob)
+	if !ok {
+		return fmt.Errorf("value %v is not a cronjob.CronJob type", value)
+	}
+
+	cp := *c
+	i.Db[key] = &cp
+	return nil
+}
+
+func (i *InMemoryCronJobStore) Get(key string) (interface{}, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	c, ok := i.Db[key]
+	if !ok {
+		return nil, fmt.Errorf("cron job with key %s does not exist", key)
+	}
+
+	cp := *c
+	return &cp, nil
+}
+
+func (i *InMemoryCronJobStore) List() (interface{}, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	cronJobs := []*cronjob.CronJob{}
+	for _, c := range i.Db {
+		cp := *c
+		cronJobs = append(cronJobs, &cp)
+	}
+
+	return cronJobs, nil
+}
+
+func (i *InMemoryCronJobStore) Count() (int, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return len(i.Db), nil
+}
+
+func (i *InMemoryCronJobStore) Delete(key string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	delete(i.Db, key)
+	return nil
+}
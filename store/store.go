@@ -0,0 +1,17 @@
+package store
+
+// Store persists values by key. Implementations decide the concrete value
+// types they accept and return.
+type Store interface {
+	Put(key string, value interface{}) error
+	Get(key string) (interface{}, error)
+	List() (interface{}, error)
+	Count() (int, error)
+	Delete(key string) error
+}
+
+// Types of store that can be selected when starting the manager or worker.
+const (
+	Memory     = "memory"
+	Persistent = "persistent"
+)
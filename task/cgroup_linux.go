@@ -0,0 +1,130 @@
+//go:build linux
+// +build linux
+
+package task
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const cgroupRoot = "/sys/fs/cgroup"
+
+// cpuPeriod is the cgroup cpu.max period in microseconds.
+const cpuPeriod = 100000
+
+// cgroupsV2Available reports whether the unified cgroup hierarchy is mounted
+// and we may create child groups in it.
+func cgroupsV2Available() bool {
+	_, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers"))
+	return err == nil
+}
+
+// createCgroup puts pid in a new cgroup limited to memory bytes and cpu cores.
+// It returns the cgroup path so it can be removed once the process exits.
+func createCgroup(name string, pid int, memory int64, cpu float64) (string, error) {
+	if !cgroupsV2Available() {
+		return "", fmt.Errorf("cgroups v2 is not available")
+	}
+
+	parent := filepath.Join(cgroupRoot, "mini-kube")
+	err := os.MkdirAll(parent, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	// Limit files only exist in a group whose parent delegates the
+	// controllers to it, so enable them all the way down.
+	var controllers []string
+	if memory > 0 {
+		controllers = append(controllers, "memory")
+	}
+	if cpu > 0 {
+		controllers = append(controllers, "cpu")
+	}
+	for _, dir := range []string{cgroupRoot, parent} {
+		err = enableControllers(dir, controllers)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	path := filepath.Join(parent, name)
+	err = os.Mkdir(path, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	if memory > 0 {
+		err = ioutil.WriteFile(filepath.Join(path, "memory.max"), []byte(strconv.FormatInt(memory, 10)), 0644)
+		if err != nil {
+			os.Remove(path)
+			return "", fmt.Errorf("unable to set memory limit: %v", err)
+		}
+	}
+
+	if cpu > 0 {
+		quota := int64(cpu * cpuPeriod)
+		limit := fmt.Sprintf("%d %d", quota, cpuPeriod)
+		err = ioutil.WriteFile(filepath.Join(path, "cpu.max"), []byte(limit), 0644)
+		if err != nil {
+			os.Remove(path)
+			return "", fmt.Errorf("unable to set cpu limit: %v", err)
+		}
+	}
+
+	err = ioutil.WriteFile(filepath.Join(path, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0644)
+	if err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("unable to move process %d into cgroup: %v", pid, err)
+	}
+
+	return path, nil
+}
+
+// enableControllers delegates controllers from the cgroup at dir to its
+// children through cgroup.subtree_control.
+func enableControllers(dir string, controllers []string) error {
+	data, err := ioutil.ReadFile(filepath.Join(dir, "cgroup.controllers"))
+	if err != nil {
+		return err
+	}
+
+	available := strings.Fields(string(data))
+	var enable []string
+	for _, c := range controllers {
+		if !contains(available, c) {
+			return fmt.Errorf("the %s controller is not available in %s", c, dir)
+		}
+		enable = append(enable, "+"+c)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, "cgroup.subtree_control"), []byte(strings.Join(enable, " ")), 0644)
+	if err != nil {
+		return fmt.Errorf("unable to enable the %s controllers in %s: %v", strings.Join(controllers, " and "), dir, err)
+	}
+
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func removeCgroup(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	return os.Remove(path)
+}
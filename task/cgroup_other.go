@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package task
+
+import "fmt"
+
+func cgroupsV2Available() bool {
+	return false
+}
+
+func createCgroup(name string, pid int, memory int64, cpu float64) (string, error) {
+	return "", fmt.Errorf("cgroups are only supported on linux")
+}
+
+func removeCgroup(path string) error {
+	return nil
+}
@@ -0,0 +1,282 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// Docker runs tasks as docker containers.
+type Docker struct {
+	Client *client.Client
+}
+
+func NewDocker() (*Docker, error) {
+	dc, err := client.NewClientWithOpts(client.FromEnv)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create docker client: %v", err)
+	}
+
+	return &Docker{Client: dc}, nil
+}
+
+func (d *Docker) Inspect(containerID string) InspectResponse {
+	ctx := context.Background()
+	resp, err := d.Client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		log.Printf("Error inspecting container: %s\n", err)
+		return InspectResponse{Error: err}
+	}
+
+	c := &Container{
+		ID:     resp.ID,
+		Name:   strings.TrimPrefix(resp.Name, "/"),
+		Status: resp.State.Status,
+	}
+
+	if resp.Config != nil {
+		c.Image = resp.Config.Image
+		c.Labels = resp.Config.Labels
+	}
+
+	if created, err := time.Parse(time.RFC3339Nano, resp.Created); err == nil {
+		c.Created = created
+	}
+
+	c.ExitCode = resp.State.ExitCode
+	if resp.NetworkSettings != nil {
+		c.Ports = resp.NetworkSettings.NetworkSettingsBase.Ports
+		c.IP = resp.NetworkSettings.DefaultNetworkSettings.IPAddress
+	}
+
+	return InspectResponse{Container: c}
+}
+
+// List returns every container, running or not, that was started for a task.
+func (d *Docker) List() ([]Container, error) {
+	ctx := context.Background()
+	f := filters.NewArgs(filters.Arg("label", TaskIDLabel))
+	containers, err := d.Client.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: f})
+	if err != nil {
+		log.Printf("Error listing containers: %v\n", err)
+		return nil, err
+	}
+
+	var result []Container
+	for _, c := range containers {
+		name := ""
+		if len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+
+		result = append(result, Container{
+			ID:      c.ID,
+			Name:    name,
+			Image:   c.Image,
+			Labels:  c.Labels,
+			Created: time.Unix(c.Created, 0).UTC(),
+			Status:  c.State,
+		})
+	}
+
+	return result, nil
+}
+
+func (d *Docker) Run(c *Config) Result {
+	ctx := context.Background()
+	reader, err := d.Client.ImagePull(ctx, c.Image, types.ImagePullOptions{})
+
+	if err != nil {
+		log.Printf("Error pulling image %v: %v\n", c.Image, err)
+	} else {
+		io.Copy(os.Stdout, reader)
+		reader.Close()
+	}
+
+	rp := container.RestartPolicy{
+		Name: c.RestartPolicy,
+	}
+
+	r := container.Resources{
+		Memory:   c.Memory,
+		NanoCPUs: int64(c.Cpu * math.Pow(10, 9)),
+	}
+
+	cc := container.Config{
+		Image:        c.Image,
+		Cmd:          c.Cmd,
+		Env:          c.Env,
+		ExposedPorts: c.ExposedPorts,
+		Labels:       c.Labels,
+	}
+
+	hc := container.HostConfig{
+		RestartPolicy: rp,
+		Resources:     r,
+		PortBindings:  c.PortBindings,
+	}
+
+	resp, err := d.Client.ContainerCreate(
+		ctx,
+		&cc,
+		&hc,
+		nil,
+		nil,
+		c.Name,
+	)
+
+	if err != nil {
+		log.Printf("Error creating container using image %s:%v\n", c.Image, err)
+		return Result{Error: err}
+	}
+
+	err = d.Client.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{})
+
+	if err != nil {
+		log.Printf("Error starting container %s:%v\n", resp.ID, err)
+		return Result{Error: err}
+	}
+
+	return Result{
+		ContainerId: resp.ID,
+		Action:      "start",
+		Result:      "success",
+	}
+}
+
+func (d *Docker) Stop(containerID string) Result {
+	ctx := context.Background()
+	log.Printf("Attempting to stop container %v", containerID)
+	err := d.Client.ContainerStop(ctx, containerID, nil)
+	if err != nil {
+		log.Printf("Error stopping container %v: %v\n", containerID, err)
+		return Result{Action: "stop", Error: err}
+	}
+
+	removeOptions := types.ContainerRemoveOptions{
+		RemoveVolumes: true,
+		RemoveLinks:   false,
+		Force:         false,
+	}
+
+	err = d.Client.ContainerRemove(ctx, containerID, removeOptions)
+
+	if err != nil {
+		log.Printf("Error removing container %v: %v\n", containerID, err)
+		return Result{Action: "stop", Error: err}
+	}
+
+	return Result{Action: "stop", Result: "success", Error: nil}
+}
+
+// Logs streams the container's stdout and stderr, demultiplexed into a
+// single plain text stream.
+func (d *Docker) Logs(containerID string, opts LogOptions) (io.ReadCloser, error) {
+	ctx := context.Background()
+	out, err := d.Client.ContainerLogs(ctx, containerID, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Tail:       opts.Tail,
+		Since:      opts.Since,
+		Follow:     opts.Follow,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := stdcopy.StdCopy(pw, pw, out)
+		out.Close()
+		pw.CloseWithError(err)
+	}()
+
+	return pr, nil
+}
+
+func (d *Docker) Stats(containerID string) (*ContainerStats, error) {
+	ctx := context.Background()
+	resp, err := d.Client.ContainerStats(ctx, containerID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var s types.StatsJSON
+	err = json.NewDecoder(resp.Body).Decode(&s)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode stats for container %s: %v", containerID, err)
+	}
+
+	cpuDelta := float64(s.CPUStats.CPUUsage.TotalUsage) - float64(s.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(s.CPUStats.SystemUsage) - float64(s.PreCPUStats.SystemUsage)
+	cpuPercent := 0.0
+	if systemDelta > 0 && cpuDelta > 0 {
+		cpuPercent = cpuDelta / systemDelta * float64(s.CPUStats.OnlineCPUs) * 100.0
+	}
+
+	return &ContainerStats{
+		CpuPercent:  cpuPercent,
+		MemoryUsage: s.MemoryStats.Usage,
+		MemoryLimit: s.MemoryStats.Limit,
+	}, nil
+}
+
+// Exec runs cmd in the container with docker exec and collects its output.
+func (d *Docker) Exec(ctx context.Context, containerID string, cmd []string) (ExecResult, error) {
+	exec, err := d.Client.ContainerExecCreate(ctx, containerID, types.ExecConfig{
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return ExecResult{}, err
+	}
+
+	resp, err := d.Client.ContainerExecAttach(ctx, exec.ID, types.ExecStartCheck{})
+	if err != nil {
+		return ExecResult{}, err
+	}
+	defer resp.Close()
+
+	// Reading the attached connection does not stop when ctx ends, so the
+	// connection is closed to stop it.
+	copied := make(chan struct{})
+	defer close(copied)
+	go func() {
+		select {
+		case <-ctx.Done():
+			resp.Close()
+		case <-copied:
+		}
+	}()
+
+	var out bytes.Buffer
+	_, err = stdcopy.StdCopy(&out, &out, resp.Reader)
+	if ctx.Err() != nil {
+		return ExecResult{}, ctx.Err()
+	}
+	if err != nil {
+		return ExecResult{}, err
+	}
+
+	inspect, err := d.Client.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		return ExecResult{}, err
+	}
+
+	return ExecResult{ExitCode: inspect.ExitCode, Output: out.String()}, nil
+}
@@ -0,0 +1,219 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+// FakeRuntime simulates a container runtime in memory. Containers start
+// running immediately and only change state when the caller makes them exit
+// or crash, which makes worker behaviour reproducible without a daemon.
+type FakeRuntime struct {
+	mu         sync.Mutex
+	containers map[string]*fakeContainer
+	nextID     int
+	// RunError, when set, is returned by the next call to Run.
+	RunError error
+	// Now returns the current time; it defaults to time.Now.
+	Now func() time.Time
+}
+
+type fakeContainer struct {
+	info   Container
+	config Config
+	logs   bytes.Buffer
+	stats  ContainerStats
+	exec   ExecResult
+}
+
+func NewFakeRuntime() *FakeRuntime {
+	return &FakeRuntime{
+		containers: make(map[string]*fakeContainer),
+		Now:        time.Now,
+	}
+}
+
+func (f *FakeRuntime) Run(c *Config) Result {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.RunError != nil {
+		err := f.RunError
+		f.RunError = nil
+		return Result{Action: "start", Error: err}
+	}
+
+	f.nextID++
+	id := fmt.Sprintf("fake-%d", f.nextID)
+	f.containers[id] = &fakeContainer{
+		info: Container{
+			ID:      id,
+			Name:    c.Name,
+			Image:   c.Image,
+			Labels:  c.Labels,
+			Created: f.Now(),
+			Status:  "running",
+			Ports:   c.PortBindings,
+			IP:      fmt.Sprintf("10.0.0.%d", f.nextID%254+1),
+		},
+		config: *c,
+	}
+
+	return Result{ContainerId: id, Action: "start", Result: "success"}
+}
+
+func (f *FakeRuntime) Stop(containerID string) Result {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.containers[containerID]; !ok {
+		return Result{Action: "stop", Error: fmt.Errorf("no such container: %s", containerID)}
+	}
+
+	delete(f.containers, containerID)
+	return Result{Action: "stop", Result: "success"}
+}
+
+func (f *FakeRuntime) Inspect(containerID string) InspectResponse {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, ok := f.containers[containerID]
+	if !ok {
+		return InspectResponse{Error: fmt.Errorf("no such container: %s", containerID)}
+	}
+
+	info := c.info
+	return InspectResponse{Container: &info}
+}
+
+func (f *FakeRuntime) List() ([]Container, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var containers []Container
+	for _, c := range f.containers {
+		containers = append(containers, c.info)
+	}
+
+	return containers, nil
+}
+
+// Logs returns what was written with WriteLog. Options are ignored.
+func (f *FakeRuntime) Logs(containerID string, opts LogOptions) (io.ReadCloser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, ok := f.containers[containerID]
+	if !ok {
+		return nil, fmt.Errorf("no such container: %s", containerID)
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(c.logs.Bytes())), nil
+}
+
+func (f *FakeRuntime) Stats(containerID string) (*ContainerStats, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, ok := f.containers[containerID]
+	if !ok {
+		return nil, fmt.Errorf("no such container: %s", containerID)
+	}
+
+	s := c.stats
+	return &s, nil
+}
+
+// Exec returns the result set with SetExec, which succeeds by default.
+func (f *FakeRuntime) Exec(ctx context.Context, containerID string, cmd []string) (ExecResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, ok := f.containers[containerID]
+	if !ok {
+		return ExecResult{}, fmt.Errorf("no such container: %s", containerID)
+	}
+
+	return c.exec, nil
+}
+
+// Exit makes a running container exit with the given code.
+func (f *FakeRuntime) Exit(containerID string, code int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, ok := f.containers[containerID]
+	if !ok {
+		return fmt.Errorf("no such container: %s", containerID)
+	}
+
+	if c.info.Status != "running" {
+		return errors.New("container is not running")
+	}
+
+	c.info.Status = "exited"
+	c.info.ExitCode = code
+	return nil
+}
+
+// Crash makes a running container die as if it was killed by the kernel.
+func (f *FakeRuntime) Crash(containerID string) error {
+	return f.Exit(containerID, 137)
+}
+
+// Remove deletes a container behind the worker's back.
+func (f *FakeRuntime) Remove(containerID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.containers, containerID)
+}
+
+// WriteLog appends output to a container's logs.
+func (f *FakeRuntime) WriteLog(containerID string, s string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, ok := f.containers[containerID]
+	if !ok {
+		return fmt.Errorf("no such container: %s", containerID)
+	}
+
+	c.logs.WriteString(s)
+	return nil
+}
+
+// SetStats sets the resource usage reported for a container.
+func (f *FakeRuntime) SetStats(containerID string, s ContainerStats) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, ok := f.containers[containerID]
+	if !ok {
+		return fmt.Errorf("no such container: %s", containerID)
+	}
+
+	c.stats = s
+	return nil
+}
+
+// SetExec sets the result of commands run in a container with Exec.
+func (f *FakeRuntime) SetExec(containerID string, r ExecResult) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, ok := f.containers[containerID]
+	if !ok {
+		return fmt.Errorf("no such container: %s", containerID)
+	}
+
+	c.exec = r
+	return nil
+}
@@ -0,0 +1,285 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Probe defaults, used for fields left at zero.
+const (
+	defaultPeriodSeconds    = 10
+	defaultTimeoutSeconds   = 1
+	defaultSuccessThreshold = 1
+	defaultFailureThreshold = 3
+)
+
+// Probe checks a running task in one of three ways: an HTTP GET that must
+// answer with a 2xx or 3xx status, a TCP connection that must be accepted, or
+// a command run inside the container that must exit with 0. Exactly one of
+// HTTPGet, TCPSocket and Exec is set.
+type Probe struct {
+	HTTPGet   *HTTPGetAction
+	TCPSocket *TCPSocketAction
+	Exec      *ExecAction
+	// InitialDelaySeconds is how long after the task started the probe
+	// first runs.
+	InitialDelaySeconds int
+	// PeriodSeconds is how often the probe runs, 10 by default.
+	PeriodSeconds int
+	// TimeoutSeconds is how long a single check may take, 1 by default.
+	TimeoutSeconds int
+	// SuccessThreshold is how many checks in a row must pass for the probe
+	// to pass again after failing, 1 by default.
+	SuccessThreshold int
+	// FailureThreshold is how many checks in a row must fail for the probe
+	// to fail, 3 by default.
+	FailureThreshold int
+}
+
+type HTTPGetAction struct {
+	Path string
+	// Port is a container port of the task. Zero means the first port the
+	// task publishes.
+	Port int
+	// Scheme is "http" or "https", "http" by default.
+	Scheme  string
+	Headers map[string]string
+}
+
+type TCPSocketAction struct {
+	// Port is a container port of the task. Zero means the first port the
+	// task publishes.
+	Port int
+}
+
+type ExecAction struct {
+	Command []string
+}
+
+// ProbeTarget is what a probe checks.
+type ProbeTarget struct {
+	// Address returns the host:port a container port of the task is
+	// reachable on. Port 0 asks for the task's first port.
+	Address func(containerPort int) (string, error)
+	// Exec runs a command in the task's container.
+	Exec func(ctx context.Context, cmd []string) (ExecResult, error)
+}
+
+// Validate checks that p has exactly one action and sensible settings.
+func (p *Probe) Validate() error {
+	actions := 0
+	if p.HTTPGet != nil {
+		actions++
+		if p.HTTPGet.Scheme != "" && p.HTTPGet.Scheme != "http" && p.HTTPGet.Scheme != "https" {
+			return fmt.Errorf("unsupported scheme %q", p.HTTPGet.Scheme)
+		}
+		if p.HTTPGet.Port < 0 {
+			return fmt.Errorf("invalid port %d", p.HTTPGet.Port)
+		}
+	}
+	if p.TCPSocket != nil {
+		actions++
+		if p.TCPSocket.Port < 0 {
+			return fmt.Errorf("invalid port %d", p.TCPSocket.Port)
+		}
+	}
+	if p.Exec != nil {
+		actions++
+		if len(p.Exec.Command) == 0 {
+			return errors.New("exec probe needs a command")
+		}
+	}
+	if actions != 1 {
+		return errors.New("probe must set exactly one of HTTPGet, TCPSocket and Exec")
+	}
+
+	if p.InitialDelaySeconds < 0 || p.PeriodSeconds < 0 || p.TimeoutSeconds < 0 ||
+		p.SuccessThreshold < 0 || p.FailureThreshold < 0 {
+		return errors.New("probe delays, periods and thresholds must not be negative")
+	}
+
+	return nil
+}
+
+// InitialDelay is how long after the task started the probe first runs.
+func (p *Probe) InitialDelay() time.Duration {
+	return time.Duration(p.InitialDelaySeconds) * time.Second
+}
+
+// Period is how often the probe runs.
+func (p *Probe) Period() time.Duration {
+	return time.Duration(orDefault(p.PeriodSeconds, defaultPeriodSeconds)) * time.Second
+}
+
+// Timeout is how long a single check may take.
+func (p *Probe) Timeout() time.Duration {
+	return time.Duration(orDefault(p.TimeoutSeconds, defaultTimeoutSeconds)) * time.Second
+}
+
+// Thresholds returns how many checks in a row must pass and fail for the
+// probe to pass and fail.
+func (p *Probe) Thresholds() (success int, failure int) {
+	return orDefault(p.SuccessThreshold, defaultSuccessThreshold), orDefault(p.FailureThreshold, defaultFailureThreshold)
+}
+
+func orDefault(v int, def int) int {
+	if v == 0 {
+		return def
+	}
+
+	return v
+}
+
+// Check runs the probe once against target and returns why it failed.
+func (p *Probe) Check(ctx context.Context, target ProbeTarget) error {
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout())
+	defer cancel()
+
+	switch {
+	case p.HTTPGet != nil:
+		return p.checkHTTP(ctx, target)
+	case p.TCPSocket != nil:
+		addr, err := target.Address(p.TCPSocket.Port)
+		if err != nil {
+			return err
+		}
+
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	case p.Exec != nil:
+		return p.checkExec(ctx, target)
+	default:
+		return errors.New("probe has no action")
+	}
+}
+
+// checkExec runs the command of p in target. A runtime may not stop the
+// command when ctx ends, so the check gives up on it then rather than wait.
+func (p *Probe) checkExec(ctx context.Context, target ProbeTarget) error {
+	type result struct {
+		r   ExecResult
+		err error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		r, err := target.Exec(ctx, p.Exec.Command)
+		done <- result{r, err}
+	}()
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			return res.err
+		}
+		if res.r.ExitCode != 0 {
+			return fmt.Errorf("command exited with %d: %s", res.r.ExitCode, res.r.Output)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("command did not finish within %v", p.Timeout())
+	}
+}
+
+func (p *Probe) checkHTTP(ctx context.Context, target ProbeTarget) error {
+	addr, err := target.Address(p.HTTPGet.Port)
+	if err != nil {
+		return err
+	}
+
+	scheme := p.HTTPGet.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	url := fmt.Sprintf("%s://%s%s", scheme, addr, p.HTTPGet.Path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range p.HTTPGet.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("GET %s returned %d", url, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// ProbeStatus follows the results of one probe of a task.
+type ProbeStatus struct {
+	// Passing flips after SuccessThreshold passed or FailureThreshold failed
+	// checks in a row.
+	Passing   bool
+	Successes int
+	Failures  int
+	LastProbe time.Time
+	LastError string
+}
+
+// Due reports whether p should run at now for a task that started at start.
+func (s *ProbeStatus) Due(p *Probe, start time.Time, now time.Time) bool {
+	if now.Before(start.Add(p.InitialDelay())) {
+		return false
+	}
+
+	return s.LastProbe.IsZero() || now.Sub(s.LastProbe) >= p.Period()
+}
+
+// Record adds the result of a check of p that ran at now.
+func (s *ProbeStatus) Record(p *Probe, err error, now time.Time) {
+	s.LastProbe = now
+	if err == nil {
+		s.Successes++
+		s.Failures = 0
+		s.LastError = ""
+		if success, _ := p.Thresholds(); s.Successes >= success {
+			s.Passing = true
+		}
+		return
+	}
+
+	s.Failures++
+	s.Successes = 0
+	s.LastError = err.Error()
+	if s.Failed(p) {
+		s.Passing = false
+	}
+}
+
+// Failed reports whether p failed FailureThreshold times in a row.
+func (s *ProbeStatus) Failed(p *Probe) bool {
+	_, failure := p.Thresholds()
+	return s.Failures >= failure
+}
+
+// Health is what the probes of a running task found. The worker running the
+// task probes it and reports its health with the task; it starts over
+// whenever the task is started again.
+type Health struct {
+	Startup   ProbeStatus
+	Liveness  ProbeStatus
+	Readiness ProbeStatus
+	// Unhealthy is set once the startup or liveness probe failed, telling
+	// the manager to restart the task.
+	Unhealthy bool
+	// Reason says which probe failed and why.
+	Reason string
+}
@@ -0,0 +1,73 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExecProbeTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	tests := []struct {
+		name string
+		exec func(ctx context.Context, cmd []string) (ExecResult, error)
+	}{
+		{
+			name: "runtime honours ctx",
+			exec: func(ctx context.Context, cmd []string) (ExecResult, error) {
+				<-ctx.Done()
+				return ExecResult{}, ctx.Err()
+			},
+		},
+		{
+			name: "runtime ignores ctx",
+			exec: func(ctx context.Context, cmd []string) (ExecResult, error) {
+				<-release
+				return ExecResult{}, nil
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Probe{Exec: &ExecAction{Command: []string{"sleep", "60"}}, TimeoutSeconds: 1}
+			start := time.Now()
+			err := p.Check(context.Background(), ProbeTarget{Exec: tt.exec})
+			if err == nil {
+				t.Fatal("Check passed, want a timeout")
+			}
+			if took := time.Since(start); took > 3*time.Second {
+				t.Errorf("Check took %v, want about %v", took, p.Timeout())
+			}
+		})
+	}
+}
+
+func TestExecProbe(t *testing.T) {
+	tests := []struct {
+		name   string
+		result ExecResult
+		err    error
+		pass   bool
+	}{
+		{name: "exit 0", result: ExecResult{ExitCode: 0}, pass: true},
+		{name: "exit 1", result: ExecResult{ExitCode: 1, Output: "not ready"}},
+		{name: "exec error", err: errors.New("no such container")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Probe{Exec: &ExecAction{Command: []string{"true"}}}
+			target := ProbeTarget{Exec: func(ctx context.Context, cmd []string) (ExecResult, error) {
+				return tt.result, tt.err
+			}}
+			err := p.Check(context.Background(), target)
+			if (err == nil) != tt.pass {
+				t.Errorf("Check() = %v, want passing: %v", err, tt.pass)
+			}
+		})
+	}
+}
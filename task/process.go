@@ -0,0 +1,468 @@
+package task
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// stopTimeout is how long a process is given to exit after SIGTERM before it
+// is killed.
+const stopTimeout = 10 * time.Second
+
+// followInterval is how often a followed log is checked for new output.
+const followInterval = 250 * time.Millisecond
+
+// Process runs tasks as child processes of the worker instead of containers.
+// Config.Cmd is executed with Config.Env added to the worker's environment,
+// and memory and CPU limits are applied through cgroups v2, without which
+// tasks that set limits fail to start.
+// Processes do not survive the worker: it stops them when it shuts down, and
+// on linux the kernel kills them if the worker dies. Reconciliation after a
+// restart reports the tasks they ran as failed, so the manager restarts them.
+type Process struct {
+	// Dir holds the stdout, stderr and combined log files of every process.
+	Dir   string
+	mu    sync.Mutex
+	procs map[string]*childProcess
+}
+
+type childProcess struct {
+	info   Container
+	memory int64
+	cmd    *exec.Cmd
+	cgroup string
+	done   chan struct{}
+}
+
+func NewProcess(dir string) (*Process, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create process directory %s: %v", dir, err)
+	}
+
+	return &Process{
+		Dir:   dir,
+		procs: make(map[string]*childProcess),
+	}, nil
+}
+
+func (p *Process) logPath(id string, stream string) string {
+	return filepath.Join(p.Dir, fmt.Sprintf("%s.%s", id, stream))
+}
+
+func (p *Process) Run(c *Config) Result {
+	if len(c.Cmd) == 0 {
+		return Result{Action: "start", Error: fmt.Errorf("task %s has no command to run", c.Name)}
+	}
+
+	id := fmt.Sprintf("proc-%s", uuid.New().String()[:12])
+	stdout, err := os.Create(p.logPath(id, "stdout"))
+	if err != nil {
+		return Result{Action: "start", Error: err}
+	}
+
+	stderr, err := os.Create(p.logPath(id, "stderr"))
+	if err != nil {
+		stdout.Close()
+		p.removeLogs(id)
+		return Result{Action: "start", Error: err}
+	}
+
+	combined, err := os.Create(p.logPath(id, "log"))
+	if err != nil {
+		stdout.Close()
+		stderr.Close()
+		p.removeLogs(id)
+		return Result{Action: "start", Error: err}
+	}
+
+	logs := &timestampWriter{w: combined}
+	cmd := exec.Command(c.Cmd[0], c.Cmd[1:]...)
+	cmd.Env = append(os.Environ(), c.Env...)
+	cmd.Stdout = io.MultiWriter(stdout, logs)
+	cmd.Stderr = io.MultiWriter(stderr, logs)
+	cmd.SysProcAttr = childAttr()
+
+	err = cmd.Start()
+	if err != nil {
+		stdout.Close()
+		stderr.Close()
+		combined.Close()
+		p.removeLogs(id)
+		log.Printf("Error starting process for task %s: %v\n", c.Name, err)
+		return Result{Action: "start", Error: err}
+	}
+
+	// The process runs unconstrained for the moment it takes to move it into
+	// its cgroup. A task whose limits cannot be applied is not run at all.
+	cgroup := ""
+	if c.Memory > 0 || c.Cpu > 0 {
+		cgroup, err = createCgroup(id, cmd.Process.Pid, c.Memory, c.Cpu)
+		if err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			stdout.Close()
+			stderr.Close()
+			logs.Close()
+			p.removeLogs(id)
+			err = fmt.Errorf("unable to apply resource limits: %v", err)
+			log.Printf("Error starting process for task %s: %v\n", c.Name, err)
+			return Result{Action: "start", Error: err}
+		}
+	}
+
+	cp := &childProcess{
+		info: Container{
+			ID:      id,
+			Name:    c.Name,
+			Image:   strings.Join(c.Cmd, " "),
+			Labels:  c.Labels,
+			Created: time.Now().UTC(),
+			Status:  "running",
+			Ports:   c.PortBindings,
+			IP:      "127.0.0.1",
+		},
+		memory: c.Memory,
+		cmd:    cmd,
+		cgroup: cgroup,
+		done:   make(chan struct{}),
+	}
+
+	p.mu.Lock()
+	p.procs[id] = cp
+	p.mu.Unlock()
+
+	go p.wait(cp, stdout, stderr, logs)
+
+	return Result{ContainerId: id, Action: "start", Result: "success"}
+}
+
+// wait reaps the process and records its exit code.
+func (p *Process) wait(cp *childProcess, stdout *os.File, stderr *os.File, logs *timestampWriter) {
+	cp.cmd.Wait()
+	stdout.Close()
+	stderr.Close()
+	logs.Close()
+
+	err := removeCgroup(cp.cgroup)
+	if err != nil {
+		log.Printf("Error removing cgroup %s: %v\n", cp.cgroup, err)
+	}
+
+	p.mu.Lock()
+	cp.info.Status = "exited"
+	cp.info.ExitCode = cp.cmd.ProcessState.ExitCode()
+	p.mu.Unlock()
+	close(cp.done)
+}
+
+func (p *Process) get(id string) (*childProcess, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	cp, ok := p.procs[id]
+	if !ok {
+		return nil, fmt.Errorf("no such process: %s", id)
+	}
+
+	return cp, nil
+}
+
+// Stop terminates the process, killing it if it ignores SIGTERM, and removes
+// its log files.
+func (p *Process) Stop(containerID string) Result {
+	cp, err := p.get(containerID)
+	if err != nil {
+		return Result{Action: "stop", Error: err}
+	}
+
+	log.Printf("Attempting to stop process %v", containerID)
+	select {
+	case <-cp.done:
+	default:
+		cp.cmd.Process.Signal(syscall.SIGTERM)
+		select {
+		case <-cp.done:
+		case <-time.After(stopTimeout):
+			log.Printf("Process %v did not exit after %v, killing it", containerID, stopTimeout)
+			cp.cmd.Process.Kill()
+			<-cp.done
+		}
+	}
+
+	p.mu.Lock()
+	delete(p.procs, containerID)
+	p.mu.Unlock()
+
+	p.removeLogs(containerID)
+	return Result{Action: "stop", Result: "success"}
+}
+
+// removeLogs removes the log files of the process with id.
+func (p *Process) removeLogs(id string) {
+	for _, stream := range []string{"stdout", "stderr", "log"} {
+		os.Remove(p.logPath(id, stream))
+	}
+}
+
+func (p *Process) Inspect(containerID string) InspectResponse {
+	cp, err := p.get(containerID)
+	if err != nil {
+		return InspectResponse{Error: err}
+	}
+
+	p.mu.Lock()
+	info := cp.info
+	p.mu.Unlock()
+	return InspectResponse{Container: &info}
+}
+
+func (p *Process) List() ([]Container, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var containers []Container
+	for _, cp := range p.procs {
+		containers = append(containers, cp.info)
+	}
+
+	return containers, nil
+}
+
+func (p *Process) Logs(containerID string, opts LogOptions) (io.ReadCloser, error) {
+	cp, err := p.get(containerID)
+	if err != nil {
+		return nil, err
+	}
+
+	since, err := ParseSince(opts.Since, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	tail := -1
+	if opts.Tail != "" && opts.Tail != "all" {
+		tail, err = strconv.Atoi(opts.Tail)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tail value %q", opts.Tail)
+		}
+	}
+
+	f, err := os.Open(p.logPath(containerID, "log"))
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		defer f.Close()
+		pw.CloseWithError(streamLogs(f, pw, since, tail, opts.Follow, cp.done))
+	}()
+
+	return pr, nil
+}
+
+func (p *Process) Stats(containerID string) (*ContainerStats, error) {
+	cp, err := p.get(containerID)
+	if err != nil {
+		return nil, err
+	}
+
+	proc, err := process.NewProcess(int32(cp.cmd.Process.Pid))
+	if err != nil {
+		return nil, err
+	}
+
+	mem, err := proc.MemoryInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	cpu, err := proc.CPUPercent()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ContainerStats{
+		CpuPercent:  cpu,
+		MemoryUsage: mem.RSS,
+		MemoryLimit: uint64(cp.memory),
+	}, nil
+}
+
+// Exec runs cmd as a separate process with the environment of the task's
+// process. The process must still be running.
+func (p *Process) Exec(ctx context.Context, containerID string, cmd []string) (ExecResult, error) {
+	cp, err := p.get(containerID)
+	if err != nil {
+		return ExecResult{}, err
+	}
+
+	select {
+	case <-cp.done:
+		return ExecResult{}, fmt.Errorf("process %s is not running", containerID)
+	default:
+	}
+
+	if len(cmd) == 0 {
+		return ExecResult{}, errors.New("no command to run")
+	}
+
+	c := exec.CommandContext(ctx, cmd[0], cmd[1:]...)
+	c.Env = cp.cmd.Env
+	out, err := c.CombinedOutput()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && ctx.Err() == nil {
+		return ExecResult{ExitCode: exitErr.ExitCode(), Output: string(out)}, nil
+	}
+	if err != nil {
+		return ExecResult{}, err
+	}
+
+	return ExecResult{Output: string(out)}, nil
+}
+
+// streamLogs copies timestamped lines from f to w, dropping the timestamps.
+// Lines older than since are skipped and only the last tail lines are kept
+// when tail is not negative. With follow it keeps waiting for new lines until
+// done is closed.
+func streamLogs(f *os.File, w io.Writer, since time.Time, tail int, follow bool, done <-chan struct{}) error {
+	var lines []string
+	r := bufio.NewReader(f)
+	partial := ""
+	for {
+		chunk, err := r.ReadString('\n')
+		partial += chunk
+		if err == nil {
+			lines = append(lines, partial)
+			partial = ""
+			continue
+		}
+
+		if err != io.EOF {
+			return err
+		}
+		break
+	}
+
+	if tail >= 0 && len(lines) > tail {
+		lines = lines[len(lines)-tail:]
+	}
+
+	for _, line := range lines {
+		err := writeLogLine(w, line, since)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !follow {
+		return nil
+	}
+
+	for {
+		chunk, err := r.ReadString('\n')
+		partial += chunk
+		if err == nil {
+			if err := writeLogLine(w, partial, since); err != nil {
+				return err
+			}
+			partial = ""
+			continue
+		}
+
+		if err != io.EOF {
+			return err
+		}
+
+		select {
+		case <-done:
+			// Drain what the process wrote before exiting.
+			rest, _ := io.ReadAll(r)
+			partial += string(rest)
+			for _, line := range strings.SplitAfter(partial, "\n") {
+				if line != "" {
+					writeLogLine(w, line, since)
+				}
+			}
+			return nil
+		case <-time.After(followInterval):
+		}
+	}
+}
+
+func writeLogLine(w io.Writer, line string, since time.Time) error {
+	parts := strings.SplitN(line, " ", 2)
+	if len(parts) != 2 {
+		return nil
+	}
+
+	ts, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err == nil && ts.Before(since) {
+		return nil
+	}
+
+	_, err = io.WriteString(w, parts[1])
+	return err
+}
+
+// timestampWriter prefixes every line written to w with the time it was
+// received, so logs can be filtered by time later on.
+type timestampWriter struct {
+	mu  sync.Mutex
+	w   io.WriteCloser
+	buf bytes.Buffer
+}
+
+func (t *timestampWriter) Write(b []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.buf.Write(b)
+	for {
+		idx := bytes.IndexByte(t.buf.Bytes(), '\n')
+		if idx < 0 {
+			break
+		}
+
+		line := t.buf.Next(idx + 1)
+		_, err := fmt.Fprintf(t.w, "%s %s", time.Now().UTC().Format(time.RFC3339Nano), line)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(b), nil
+}
+
+// Flush writes out a trailing line without a newline.
+func (t *timestampWriter) Flush() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.buf.Len() > 0 {
+		fmt.Fprintf(t.w, "%s %s\n", time.Now().UTC().Format(time.RFC3339Nano), t.buf.String())
+		t.buf.Reset()
+	}
+}
+
+func (t *timestampWriter) Close() error {
+	t.Flush()
+	return t.w.Close()
+}
@@ -0,0 +1,16 @@
+//go:build linux
+// +build linux
+
+package task
+
+import "syscall"
+
+// childAttr puts a task's process in its own process group, so signals sent
+// to the worker's group do not reach it, and has the kernel kill it when the
+// worker dies.
+func childAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{
+		Setpgid:   true,
+		Pdeathsig: syscall.SIGKILL,
+	}
+}
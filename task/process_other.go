@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package task
+
+import "syscall"
+
+// childAttr leaves a task's process with the defaults, as only linux can tie
+// its life to the worker's.
+func childAttr() *syscall.SysProcAttr {
+	return nil
+}
@@ -0,0 +1,73 @@
+package task
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Restart policies, named by Task.RestartPolicy, decide whether the manager
+// restarts a task that failed, exited or failed its startup or liveness
+// probe. OnFailure is the default.
+const (
+	RestartAlways    = "Always"
+	RestartOnFailure = "OnFailure"
+	RestartNever     = "Never"
+)
+
+// CrashLoopBackOff is the status of a task the manager waits to restart.
+const CrashLoopBackOff = "CrashLoopBackOff"
+
+// maxRestartHistory is how many restarts are kept in Task.Restarts.
+const maxRestartHistory = 10
+
+// Restart records a restart of a task by the manager.
+type Restart struct {
+	Time   time.Time
+	Reason string
+}
+
+// ParseRestartPolicy returns the restart policy named by s. Case and dashes
+// are ignored, so the container runtime's "on-failure" is OnFailure.
+func ParseRestartPolicy(s string) (string, error) {
+	switch strings.ToLower(strings.ReplaceAll(s, "-", "")) {
+	case "", "onfailure":
+		return RestartOnFailure, nil
+	case "always":
+		return RestartAlways, nil
+	case "never", "no":
+		return RestartNever, nil
+	default:
+		return "", fmt.Errorf("unknown restart policy %q, want Always, OnFailure or Never", s)
+	}
+}
+
+// Restart returns the restart policy of t, OnFailure when it has none or an
+// unknown one.
+func (t *Task) Restart() string {
+	policy, err := ParseRestartPolicy(t.RestartPolicy)
+	if err != nil {
+		return RestartOnFailure
+	}
+
+	return policy
+}
+
+// RecordRestart counts a restart of t at now and keeps why it happened.
+func (t *Task) RecordRestart(reason string, now time.Time) {
+	t.RestartCount++
+	t.RestartAt = time.Time{}
+	t.Restarts = append(t.Restarts, Restart{Time: now, Reason: reason})
+	if len(t.Restarts) > maxRestartHistory {
+		t.Restarts = t.Restarts[len(t.Restarts)-maxRestartHistory:]
+	}
+}
+
+// SetStatus fills in t.Status as of now: the state of t, or CrashLoopBackOff
+// while the manager waits to restart it.
+func (t *Task) SetStatus(now time.Time) {
+	t.Status = t.State.String()
+	if !t.RestartAt.IsZero() && now.Before(t.RestartAt) {
+		t.Status = CrashLoopBackOff
+	}
+}
@@ -0,0 +1,91 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/docker/go-connections/nat"
+)
+
+// Runtime runs the workload of a task and reports on it. Container IDs are
+// opaque to callers and only meaningful to the runtime that returned them.
+type Runtime interface {
+	Run(c *Config) Result
+	Stop(containerID string) Result
+	Inspect(containerID string) InspectResponse
+	// List returns every container the runtime started for a task.
+	List() ([]Container, error)
+	Logs(containerID string, opts LogOptions) (io.ReadCloser, error)
+	Stats(containerID string) (*ContainerStats, error)
+	// Exec runs cmd inside the container and waits for it to exit or for
+	// ctx to end.
+	Exec(ctx context.Context, containerID string, cmd []string) (ExecResult, error)
+}
+
+type Result struct {
+	Error       error
+	Action      string
+	ContainerId string
+	Result      string
+}
+
+// Container describes a workload as seen by its runtime.
+type Container struct {
+	ID      string
+	Name    string
+	Image   string
+	Labels  map[string]string
+	Created time.Time
+	// Status is one of "created", "running" or "exited".
+	Status   string
+	ExitCode int
+	Ports    nat.PortMap
+	IP       string
+}
+
+type InspectResponse struct {
+	Error     error
+	Container *Container
+}
+
+type LogOptions struct {
+	// Tail is the number of lines to show from the end of the logs, or "all".
+	Tail string
+	// Since is an RFC 3339 timestamp or a Go duration relative to now.
+	Since  string
+	Follow bool
+}
+
+// ExecResult is the outcome of a command run with Runtime.Exec.
+type ExecResult struct {
+	ExitCode int
+	// Output holds the command's stdout and stderr.
+	Output string
+}
+
+type ContainerStats struct {
+	CpuPercent  float64
+	MemoryUsage uint64
+	MemoryLimit uint64
+}
+
+// ParseSince converts LogOptions.Since to an absolute time. An empty value
+// returns the zero time, which matches every log line.
+func ParseSince(since string, now time.Time) (time.Time, error) {
+	if since == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, since); err == nil {
+		return t, nil
+	}
+
+	d, err := time.ParseDuration(since)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid since value %q: expected RFC 3339 time or duration", since)
+	}
+
+	return now.Add(-d), nil
+}
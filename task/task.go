@@ -1,17 +1,12 @@
 package task
 
 import (
-	"context"
-	"io"
-	"log"
-	"math"
-	"os"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
 	"time"
 
-	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/client"
-	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/docker/go-connections/nat"
 	"github.com/google/uuid"
 )
@@ -24,16 +19,74 @@ const (
 	Completed
 	Running
 	Failed
+	// Stopping tasks were asked to stop and wait for their container to.
+	Stopping
+	// Restarting tasks have their container replaced on the same worker.
+	Restarting
+	// Unknown tasks run on a worker the manager has not heard from lately.
+	Unknown
+	// Lost tasks ran on a worker that is gone.
+	Lost
 )
 
+func (s State) String() string {
+	switch s {
+	case Pending:
+		return "Pending"
+	case Scheduled:
+		return "Scheduled"
+	case Completed:
+		return "Completed"
+	case Running:
+		return "Running"
+	case Failed:
+		return "Failed"
+	case Stopping:
+		return "Stopping"
+	case Restarting:
+		return "Restarting"
+	case Unknown:
+		return "Unknown"
+	case Lost:
+		return "Lost"
+	default:
+		return fmt.Sprintf("State(%d)", int(s))
+	}
+}
+
 var stateTransitionMap = map[State][]State{
-	Pending:   {Scheduled},
-	Scheduled: {Scheduled, Running, Failed},
-	Running:   {Running, Completed, Failed, Scheduled},
-	Completed: {},
-	Failed:    {Scheduled},
+	Pending:    {Scheduled, Completed},
+	Scheduled:  {Scheduled, Running, Failed, Stopping, Unknown, Lost},
+	Running:    {Running, Completed, Failed, Scheduled, Stopping, Restarting, Unknown, Lost},
+	Stopping:   {Stopping, Completed, Failed, Unknown, Lost},
+	Restarting: {Restarting, Running, Completed, Failed, Stopping, Unknown, Lost},
+	Unknown:    {Unknown, Scheduled, Running, Completed, Failed, Stopping, Restarting, Lost},
+	Lost:       {Scheduled, Running, Completed, Failed},
+	Completed:  {Restarting, Lost},
+	Failed:     {Pending, Scheduled, Restarting, Stopping, Completed, Lost},
 }
 
+// Reasons a task enters a state, kept in Task.Reason.
+const (
+	ReasonCreated          = "Created"
+	ReasonScheduled        = "Scheduled"
+	ReasonRejected         = "Rejected"
+	ReasonStarted          = "Started"
+	ReasonStartError       = "StartError"
+	ReasonCompleted        = "Completed"
+	ReasonError            = "Error"
+	ReasonUnhealthy        = "Unhealthy"
+	ReasonContainerMissing = "ContainerMissing"
+	ReasonAdopted          = "Adopted"
+	ReasonStopRequested    = "StopRequested"
+	ReasonStopped          = "Stopped"
+	ReasonRestart          = "Restart"
+	ReasonRestartFailed    = "RestartFailed"
+	ReasonRestartLimit     = "RestartLimit"
+	ReasonNodeNotReady     = "NodeNotReady"
+	ReasonNodeLost         = "NodeLost"
+)
+
 func Contains(states []State, state State) bool {
 	for _, s := range states {
 		if s == state {
@@ -48,12 +101,54 @@ func ValidStateTransition(src State, dst State) bool {
 	return Contains(stateTransitionMap[src], dst)
 }
 
+// ExitState is the state of a task whose container exited with code: a clean
+// exit completes the task and any other code fails it.
+func ExitState(code int) State {
+	if code == 0 {
+		return Completed
+	}
+
+	return Failed
+}
+
+// SetState moves t to state, recording reason and a message explaining it.
+// Transitions the state machine does not allow are refused.
+func (t *Task) SetState(state State, reason string, message string) error {
+	if t.State != state && !ValidStateTransition(t.State, state) {
+		return fmt.Errorf("invalid transition of task %v from %v to %v", t.ID, t.State, state)
+	}
+
+	t.State = state
+	t.Reason = reason
+	t.Message = message
+	return nil
+}
+
+// Exited records that the container of t exited with code.
+func (t *Task) Exited(code int) error {
+	t.ExitCode = code
+	reason := ReasonCompleted
+	if code != 0 {
+		reason = ReasonError
+	}
+
+	return t.SetState(ExitState(code), reason, fmt.Sprintf("container exited with code %d", code))
+}
+
+// Succeeded reports whether t completed by exiting cleanly, rather than by
+// being stopped or lost with its node.
+func (t *Task) Succeeded() bool {
+	return t.State == Completed && t.Reason == ReasonCompleted
+}
+
 type Task struct {
 	ID            uuid.UUID
 	ContainerID   string
 	Name          string
 	State         State
 	Image         string
+	Cmd           []string
+	Env           []string
 	Memory        int64
 	Disk          int64
 	Cpu           float64
@@ -63,182 +158,215 @@ type Task struct {
 	StartTime     time.Time
 	FinishTime    time.Time
 	HostPorts     nat.PortMap
-	HealthCheck   string
-	RestartCount  int
-}
-
-type TaskEvent struct {
-	ID        uuid.UUID
-	State     State
-	Timestamp time.Time
-	Task      Task
-}
-
-type Config struct {
-	Name         string
-	AttachStdin  bool
-	AttachStdout bool
-	AttachStderr bool
-	Cmd          []string
-	Image        string
-	// ExposedPorts list of ports exposed
-	ExposedPorts nat.PortSet
-	Cpu          float64
-	Memory       int64
-	Disk         int64
-	Env          []string
-	// RestartPolicy for the container ["", "always", "unless-stopped", "on-failure"]
-	RestartPolicy string
-	PortBindings  nat.PortMap
+	// IP is the address of the task's container on its worker.
+	IP string
+	// HealthCheck is an HTTP path on the task's first port. It is shorthand
+	// for a liveness and readiness probe of that path.
+	HealthCheck string
+	// LivenessProbe restarts the task when it fails and ReadinessProbe
+	// decides whether the task is Ready. Neither runs before StartupProbe
+	// passed, and a failing StartupProbe restarts the task too.
+	LivenessProbe  *Probe
+	ReadinessProbe *Probe
+	StartupProbe   *Probe
+	// Ready is set while the task runs and passes its readiness probe.
+	Ready bool
+	// Health holds the probe results the worker reports for the task.
+	Health       Health
+	RestartCount int
+	// Restarts holds the most recent restarts by the manager.
+	Restarts []Restart
+	// RestartAt is when the manager restarts the task next while it backs
+	// off from restarting a crashing task.
+	RestartAt time.Time
+	// Status is the state of the task as shown to users, CrashLoopBackOff
+	// while the manager backs off. The manager fills it in when it serves
+	// the task.
+	Status string
+	// Reason says why the task entered its state, as one of the Reason
+	// constants, and Message explains it.
+	Reason  string
+	Message string
+	// ExitCode is the exit code of the task's container once it exited.
+	ExitCode int
+	// Owner names the controller that created the task, such as
+	// "deployment/web". It is empty for tasks submitted directly.
+	Owner string
+	// Revision is the revision of the owner's template the task was
+	// created from.
+	Revision int
 }
 
-func NewConfig(t *Task) *Config {
-	pBindings := nat.PortMap{}
-	for port, val := range t.PortBindings {
-		pBindings[nat.Port(port)] = []nat.PortBinding{
-			{
-				HostIP:   "0.0.0.0",
-				HostPort: val,
-			},
-		}
-	}
-
-	return &Config{
-		Name:          t.Name,
-		ExposedPorts:  t.ExposedPorts,
-		Image:         t.Image,
-		Cpu:           t.Cpu,
-		Memory:        t.Memory,
-		Disk:          t.Disk,
-		RestartPolicy: t.RestartPolicy,
-		PortBindings:  pBindings,
+// Liveness returns the liveness probe of t, if it has one.
+func (t *Task) Liveness() *Probe {
+	if t.LivenessProbe != nil {
+		return t.LivenessProbe
 	}
-}
 
-type Docker struct {
-	Client *client.Client
-	Config Config
+	return t.healthCheckProbe()
 }
 
-func NewDocker(c *Config) *Docker {
-	dc, _ := client.NewClientWithOpts(client.FromEnv)
-	return &Docker{
-		Client: dc,
-		Config: *c,
+// Readiness returns the readiness probe of t, if it has one.
+func (t *Task) Readiness() *Probe {
+	if t.ReadinessProbe != nil {
+		return t.ReadinessProbe
 	}
-}
-
-type DockerResult struct {
-	Error       error
-	Action      string
-	ContainerId string
-	Result      string
-}
 
-type DockerInspectResponse struct {
-	Error     error
-	Container *types.ContainerJSON
+	return t.healthCheckProbe()
 }
 
-func (d *Docker) Inspect(containerID string) DockerInspectResponse {
-	dc, _ := client.NewClientWithOpts(client.FromEnv)
-	ctx := context.Background()
-	resp, err := dc.ContainerInspect(ctx, containerID)
-	if err != nil {
-		log.Printf("Error inspecting container: %s\n", err)
-		return DockerInspectResponse{Error: err}
+// healthCheckProbe turns HealthCheck into a probe. Tasks without ports are
+// not checked.
+func (t *Task) healthCheckProbe() *Probe {
+	if t.HealthCheck == "" || (len(t.HostPorts) == 0 && len(t.ExposedPorts) == 0) {
+		return nil
 	}
 
-	return DockerInspectResponse{Container: &resp}
+	return &Probe{HTTPGet: &HTTPGetAction{Path: t.HealthCheck}}
 }
 
-func (d *Docker) Run() DockerResult {
-	ctx := context.Background()
-	reader, err := d.Client.ImagePull(ctx, d.Config.Image, types.ImagePullOptions{})
-
-	if err != nil {
-		log.Printf("Error pulling image %v: %v\n", d.Config.Image, err)
+// Address returns the host:port a container port of t is reachable on from
+// its worker: the host port it is published on, or the container's IP for a
+// port that is not published. Port 0 asks for t's lowest port.
+func (t *Task) Address(containerPort int) (string, error) {
+	if containerPort == 0 {
+		containerPort = t.firstPort()
+		if containerPort == 0 {
+			return "", fmt.Errorf("task %v has no ports", t.ID)
+		}
 	}
 
-	io.Copy(os.Stdout, reader)
+	for port, bindings := range t.HostPorts {
+		if port.Int() != containerPort || len(bindings) == 0 {
+			continue
+		}
 
-	rp := container.RestartPolicy{
-		Name: d.Config.RestartPolicy,
+		host := bindings[0].HostIP
+		if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+			host = "127.0.0.1"
+		}
+		return net.JoinHostPort(host, bindings[0].HostPort), nil
 	}
 
-	r := container.Resources{
-		Memory:   d.Config.Memory,
-		NanoCPUs: int64(d.Config.Cpu * math.Pow(10, 9)),
+	if t.IP == "" {
+		return "", fmt.Errorf("task %v does not publish port %d and has no IP", t.ID, containerPort)
 	}
 
-	cc := container.Config{
-		Image:        d.Config.Image,
-		Env:          d.Config.Env,
-		ExposedPorts: d.Config.ExposedPorts,
-	}
+	return net.JoinHostPort(t.IP, strconv.Itoa(containerPort)), nil
+}
 
-	hc := container.HostConfig{
-		RestartPolicy: rp,
-		Resources:     r,
-		PortBindings:  d.Config.PortBindings,
+// firstPort returns the lowest port t publishes or exposes, or 0.
+func (t *Task) firstPort() int {
+	ports := make([]int, 0, len(t.HostPorts)+len(t.ExposedPorts))
+	for port := range t.HostPorts {
+		ports = append(ports, port.Int())
 	}
-
-	resp, err := d.Client.ContainerCreate(
-		ctx,
-		&cc,
-		&hc,
-		nil,
-		nil,
-		d.Config.Name,
-	)
-
-	if err != nil {
-		log.Printf("Error creating container using image %s:%v\n", d.Config.Image, err)
-		return DockerResult{Error: err}
+	for port := range t.ExposedPorts {
+		ports = append(ports, port.Int())
+	}
+	if len(ports) == 0 {
+		return 0
 	}
 
-	err = d.Client.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{})
+	sort.Ints(ports)
+	return ports[0]
+}
 
+// Validate checks the probes and restart policy t declares.
+func (t *Task) Validate() error {
+	_, err := ParseRestartPolicy(t.RestartPolicy)
 	if err != nil {
-		log.Printf("Error starting container %s:%v\n", resp.ID, err)
-		return DockerResult{Error: err}
+		return err
 	}
 
-	out, err := d.Client.ContainerLogs(ctx, resp.ID, types.ContainerLogsOptions{ShowStdout: true, ShowStderr: true})
+	probes := []struct {
+		name  string
+		probe *Probe
+	}{
+		{"liveness", t.LivenessProbe},
+		{"readiness", t.ReadinessProbe},
+		{"startup", t.StartupProbe},
+	}
+	for _, p := range probes {
+		if p.probe == nil {
+			continue
+		}
 
-	if err != nil {
-		log.Printf("Error getting logs for container %s: %v\n", resp.ID, err)
-		return DockerResult{Error: err}
+		err = p.probe.Validate()
+		if err != nil {
+			return fmt.Errorf("invalid %s probe: %v", p.name, err)
+		}
 	}
 
-	stdcopy.StdCopy(os.Stdout, os.Stderr, out)
+	return nil
+}
 
-	return DockerResult{
-		ContainerId: resp.ID,
-		Action:      "start",
-		Result:      "success",
-	}
+type TaskEvent struct {
+	ID        uuid.UUID
+	State     State
+	Timestamp time.Time
+	Task      Task
 }
 
-func (d *Docker) Stop(containerID string) DockerResult {
-	ctx := context.Background()
-	log.Printf("Attempting to stop container %v", containerID)
-	err := d.Client.ContainerStop(ctx, containerID, nil)
-	if err != nil {
-		panic(err)
-	}
+// Event records something that happened to a task: the state it entered, or
+// a probe failing while it ran, and why.
+type Event struct {
+	ID        uuid.UUID
+	TaskID    uuid.UUID
+	TaskName  string
+	Timestamp time.Time
+	State     State
+	Reason    string
+	Message   string
+	// Node is the worker the task was placed on, if any.
+	Node string
+}
 
-	removeOptions := types.ContainerRemoveOptions{
-		RemoveVolumes: true,
-		RemoveLinks:   false,
-		Force:         false,
-	}
+type Config struct {
+	Name         string
+	AttachStdin  bool
+	AttachStdout bool
+	AttachStderr bool
+	Cmd          []string
+	Image        string
+	// ExposedPorts list of ports exposed
+	ExposedPorts nat.PortSet
+	Cpu          float64
+	Memory       int64
+	Disk         int64
+	Env          []string
+	// RestartPolicy for the container ["", "always", "unless-stopped", "on-failure"].
+	// Tasks leave it empty as the manager restarts them.
+	RestartPolicy string
+	PortBindings  nat.PortMap
+	// Labels attached to the container, used to find it again after a restart.
+	Labels map[string]string
+}
 
-	err = d.Client.ContainerRemove(ctx, containerID, removeOptions)
+// TaskIDLabel is the container label holding the ID of the task it runs.
+const TaskIDLabel = "mini-kube.task-id"
 
-	if err != nil {
-		panic(err)
+func NewConfig(t *Task) *Config {
+	pBindings := nat.PortMap{}
+	for port, val := range t.PortBindings {
+		pBindings[nat.Port(port)] = []nat.PortBinding{
+			{
+				HostIP:   "0.0.0.0",
+				HostPort: val,
+			},
+		}
 	}
 
-	return DockerResult{Action: "stop", Result: "success", Error: nil}
+	return &Config{
+		Name:         t.Name,
+		ExposedPorts: t.ExposedPorts,
+		Image:        t.Image,
+		Cmd:          t.Cmd,
+		Env:          t.Env,
+		Cpu:          t.Cpu,
+		Memory:       t.Memory,
+		Disk:         t.Disk,
+		PortBindings: pBindings,
+		Labels:       map[string]string{TaskIDLabel: t.ID.String()},
+	}
 }
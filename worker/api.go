@@ -1,8 +1,11 @@
 package worker
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"sync"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -12,6 +15,9 @@ type Api struct {
 	Port    int
 	Worker  *Worker
 	Router  *chi.Mux
+	// mu guards server, which is set once Start is called.
+	mu     sync.Mutex
+	server *http.Server
 }
 
 func (api *Api) initRouter() {
@@ -24,11 +30,40 @@ func (api *Api) initRouter() {
 		r.Get("/", api.GetTasksHandler)
 		r.Route("/{taskID}", func(r chi.Router) {
 			r.Delete("/", api.StopTaskHandler)
+			r.Get("/logs", api.GetTaskLogsHandler)
+			r.Post("/exec", api.ExecTaskHandler)
 		})
 	})
 }
 
-func (api *Api) Start() {
+// Start serves the API until Shutdown is called.
+func (api *Api) Start() error {
 	api.initRouter()
-	http.ListenAndServe(fmt.Sprintf("%s:%d", api.Address, api.Port), api.Router)
+	api.mu.Lock()
+	api.server = &http.Server{
+		Addr:    fmt.Sprintf("%s:%d", api.Address, api.Port),
+		Handler: api.Router,
+	}
+	server := api.server
+	api.mu.Unlock()
+
+	err := server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// Shutdown stops accepting connections and waits for active requests to
+// finish until ctx ends.
+func (api *Api) Shutdown(ctx context.Context) error {
+	api.mu.Lock()
+	server := api.server
+	api.mu.Unlock()
+	if server == nil {
+		return nil
+	}
+
+	return server.Shutdown(ctx)
 }
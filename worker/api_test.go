@@ -0,0 +1,193 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codding-buddha/mini-kube/task"
+	"github.com/google/uuid"
+)
+
+// fakeManager accepts registrations and heartbeats.
+func fakeManager() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/nodes" {
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+// eventually polls cond until it holds or the timeout runs out.
+func eventually(t *testing.T, timeout time.Duration, cond func() bool) bool {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return cond()
+}
+
+// TestConcurrentAPIAndLoops starts, stops and lists tasks through the API
+// while the run, update, stats, heartbeat and probe loops run. It is meant
+// to be run with -race.
+func TestConcurrentAPIAndLoops(t *testing.T) {
+	w, rt := newTestWorker(t)
+	w.RunInterval = 5 * time.Millisecond
+	w.UpdateInterval = 5 * time.Millisecond
+	w.StatsInterval = 50 * time.Millisecond
+	w.HeartbeatInterval = 20 * time.Millisecond
+
+	m := fakeManager()
+	defer m.Close()
+	w.Manager = strings.TrimPrefix(m.URL, "http://")
+
+	api := &Api{Worker: w}
+	api.initRouter()
+	srv := httptest.NewServer(api.Router)
+	defer srv.Close()
+	w.Address = strings.TrimPrefix(srv.URL, "http://")
+
+	w.Start()
+
+	const clients = 8
+	const tasksPerClient = 5
+	ids := make(chan uuid.UUID, clients*tasksPerClient)
+	var wg sync.WaitGroup
+	for c := 0; c < clients; c++ {
+		wg.Add(1)
+		go func(c int) {
+			defer wg.Done()
+			for i := 0; i < tasksPerClient; i++ {
+				tk := newTask()
+				tk.Name = fmt.Sprintf("task-%d-%d", c, i)
+				data, _ := json.Marshal(task.TaskEvent{ID: uuid.New(), State: task.Scheduled, Timestamp: time.Now(), Task: tk})
+				resp, err := http.Post(srv.URL+"/tasks", "application/json", bytes.NewBuffer(data))
+				if err != nil {
+					t.Errorf("POST /tasks: %v", err)
+					return
+				}
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusCreated {
+					t.Errorf("POST /tasks: status %d", resp.StatusCode)
+				}
+				ids <- tk.ID
+
+				for _, path := range []string{"/tasks", "/stats"} {
+					resp, err := http.Get(srv.URL + path)
+					if err != nil {
+						t.Errorf("GET %s: %v", path, err)
+						continue
+					}
+					resp.Body.Close()
+				}
+			}
+		}(c)
+	}
+
+	// Containers exit and crash while the clients work.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			containers, _ := rt.List()
+			for j, c := range containers {
+				switch j % 3 {
+				case 0:
+					rt.Exit(c.ID, 0)
+				case 1:
+					rt.Crash(c.ID)
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+	wg.Wait()
+	close(ids)
+
+	// Stop every task that is left running through the API.
+	var started []uuid.UUID
+	for id := range ids {
+		started = append(started, id)
+	}
+	if !eventually(t, 5*time.Second, func() bool { return len(w.GetTasks()) == len(started) }) {
+		t.Fatalf("worker stored %d tasks, want %d", len(w.GetTasks()), len(started))
+	}
+
+	var stops sync.WaitGroup
+	for _, id := range started {
+		stops.Add(1)
+		go func(id uuid.UUID) {
+			defer stops.Done()
+			req, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/tasks/%s", srv.URL, id), nil)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Errorf("DELETE /tasks/%s: %v", id, err)
+				return
+			}
+			resp.Body.Close()
+		}(id)
+	}
+	stops.Wait()
+
+	finished := func() bool {
+		for _, tk := range w.GetTasks() {
+			if tk.State != task.Completed && tk.State != task.Failed {
+				return false
+			}
+		}
+		return true
+	}
+	if !eventually(t, 5*time.Second, finished) {
+		for _, tk := range w.GetTasks() {
+			t.Logf("task %v is %v (%s)", tk.ID, tk.State, tk.Reason)
+		}
+		t.Fatal("tasks did not all finish")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := w.Stop(ctx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	containers, _ := rt.List()
+	for _, c := range containers {
+		if c.Status == "running" {
+			t.Errorf("container %v still runs after its task was stopped", c.ID)
+		}
+	}
+}
+
+// TestStopRunsQueuedRequests checks that requests the API accepted are
+// carried out when the worker stops before its run loop got to them.
+func TestStopRunsQueuedRequests(t *testing.T) {
+	w, _ := newTestWorker(t)
+	tk := newTask()
+	w.AddTask(tk)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := w.Stop(ctx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	stored := getTask(t, w, tk.ID)
+	if stored.State != task.Running {
+		t.Errorf("queued task is %v after Stop, want Running", stored.State)
+	}
+}
@@ -13,6 +13,10 @@ import (
 )
 
 func (api *Api) StartTaskHandler(w http.ResponseWriter, r *http.Request) {
+	if api.refuseWhileDraining(w) {
+		return
+	}
+
 	d := json.NewDecoder(r.Body)
 	d.DisallowUnknownFields()
 
@@ -46,21 +50,26 @@ func (api *Api) GetTasksHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (api *Api) StopTaskHandler(w http.ResponseWriter, r *http.Request) {
+	if api.refuseWhileDraining(w) {
+		return
+	}
+
 	taskID := chi.URLParam(r, "taskID")
 
 	if taskID == "" {
 		log.Printf("No taskID passed in request.\n")
 		w.WriteHeader(http.StatusBadRequest)
+		return
 	}
 
 	tID, _ := uuid.Parse(taskID)
-	_, ok := api.Worker.Db[tID]
-	if !ok {
+	taskToStop, err := api.Worker.GetTask(tID)
+	if err != nil {
 		log.Printf("No task with ID %v found.", tID)
-		w.WriteHeader(http.StatusBadRequest)
+		w.WriteHeader(http.StatusNotFound)
+		return
 	}
 
-	taskToStop := api.Worker.Db[tID]
 	taskCopy := *taskToStop
 	taskCopy.State = task.Completed
 	api.Worker.AddTask(taskCopy)
@@ -71,5 +80,102 @@ func (api *Api) StopTaskHandler(w http.ResponseWriter, r *http.Request) {
 func (a *Api) GetStatsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(a.Worker.Stats)
+	json.NewEncoder(w).Encode(a.Worker.GetStats())
+}
+
+func (api *Api) GetTaskLogsHandler(w http.ResponseWriter, r *http.Request) {
+	tID, err := uuid.Parse(chi.URLParam(r, "taskID"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(common.ErrResponse{
+			HTTPStatusCode: http.StatusBadRequest,
+			Message:        fmt.Sprintf("Invalid task ID: %v", err),
+		})
+		return
+	}
+
+	q := r.URL.Query()
+	opts := task.LogOptions{
+		Tail:   q.Get("tail"),
+		Since:  q.Get("since"),
+		Follow: q.Get("follow") == "true",
+	}
+
+	logs, err := api.Worker.TaskLogs(tID, opts)
+	if err != nil {
+		log.Printf("Error getting logs for task %v: %v", tID, err)
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(common.ErrResponse{
+			HTTPStatusCode: http.StatusNotFound,
+			Message:        err.Error(),
+		})
+		return
+	}
+	defer logs.Close()
+
+	// Closing the logs unblocks a followed stream when the client goes away.
+	go func() {
+		<-r.Context().Done()
+		logs.Close()
+	}()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	common.CopyAndFlush(w, logs)
+}
+
+// ExecTaskHandler runs the command in the body in the task's container and
+// answers with its exit code and output.
+func (api *Api) ExecTaskHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	tID, err := uuid.Parse(chi.URLParam(r, "taskID"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(common.ErrResponse{
+			HTTPStatusCode: http.StatusBadRequest,
+			Message:        fmt.Sprintf("Invalid task ID: %v", err),
+		})
+		return
+	}
+
+	var action task.ExecAction
+	err = json.NewDecoder(r.Body).Decode(&action)
+	if err != nil || len(action.Command) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(common.ErrResponse{
+			HTTPStatusCode: http.StatusBadRequest,
+			Message:        "Body must hold a Command to run",
+		})
+		return
+	}
+
+	result, err := api.Worker.ExecTask(r.Context(), tID, action.Command)
+	if err != nil {
+		log.Printf("Error running command in task %v: %v", tID, err)
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(common.ErrResponse{
+			HTTPStatusCode: http.StatusConflict,
+			Message:        err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
+
+// refuseWhileDraining answers with 503 Service Unavailable while the worker
+// shuts down, so the manager sends the request elsewhere or later.
+func (api *Api) refuseWhileDraining(w http.ResponseWriter) bool {
+	if !api.Worker.Draining() {
+		return false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(common.ErrResponse{
+		HTTPStatusCode: http.StatusServiceUnavailable,
+		Message:        "worker is shutting down",
+	})
+	return true
 }
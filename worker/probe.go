@@ -0,0 +1,183 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/codding-buddha/mini-kube/task"
+	"github.com/google/uuid"
+)
+
+// probeTick is how often the worker looks for probes that are due.
+const probeTick = time.Second
+
+// Probe kinds.
+const (
+	startupProbe   = "startup"
+	livenessProbe  = "liveness"
+	readinessProbe = "readiness"
+)
+
+// probeCheck is a probe of a task that is due to run.
+type probeCheck struct {
+	taskID      uuid.UUID
+	containerID string
+	kind        string
+	probe       *task.Probe
+	target      task.ProbeTarget
+	err         error
+}
+
+// ProbeTasks runs the startup, liveness and readiness probes of running tasks
+// as they fall due.
+func (w *Worker) ProbeTasks() {
+	for {
+		w.probeTasks()
+		if !w.sleep(probeTick) {
+			return
+		}
+	}
+}
+
+// probeTasks runs the probes that are due, all at once, and records their
+// results on the tasks for the manager to act on: a failed startup or
+// liveness probe marks the task unhealthy and the readiness probe decides
+// whether it is ready.
+func (w *Worker) probeTasks() {
+	checks := w.dueProbes(time.Now())
+
+	var wg sync.WaitGroup
+	for _, c := range checks {
+		wg.Add(1)
+		go func(c *probeCheck) {
+			defer wg.Done()
+			c.err = c.probe.Check(context.Background(), c.target)
+		}(c)
+	}
+	wg.Wait()
+
+	w.recordProbes(checks, time.Now())
+}
+
+// dueProbes returns the probes of running tasks that should run at now and
+// marks tasks without a readiness probe ready once they started.
+func (w *Worker) dueProbes(now time.Time) []*probeCheck {
+	w.taskMu.Lock()
+	defer w.taskMu.Unlock()
+
+	var checks []*probeCheck
+	for _, t := range w.GetTasks() {
+		if t.State != task.Running || t.Health.Unhealthy {
+			continue
+		}
+
+		h := &t.Health
+		var due []string
+		if t.StartupProbe != nil && !h.Startup.Passing {
+			if h.Startup.Due(t.StartupProbe, t.StartTime, now) {
+				due = append(due, startupProbe)
+			}
+		} else {
+			if live := t.Liveness(); live != nil && h.Liveness.Due(live, t.StartTime, now) {
+				due = append(due, livenessProbe)
+			}
+			if ready := t.Readiness(); ready == nil {
+				w.setReady(t, true)
+			} else if h.Readiness.Due(ready, t.StartTime, now) {
+				due = append(due, readinessProbe)
+			}
+		}
+
+		for _, kind := range due {
+			checks = append(checks, &probeCheck{
+				taskID:      t.ID,
+				containerID: t.ContainerID,
+				kind:        kind,
+				probe:       probeOf(t, kind),
+				target:      w.probeTarget(*t),
+			})
+		}
+	}
+
+	return checks
+}
+
+// recordProbes stores the results of checks on their tasks.
+func (w *Worker) recordProbes(checks []*probeCheck, now time.Time) {
+	w.taskMu.Lock()
+	defer w.taskMu.Unlock()
+
+	for _, c := range checks {
+		t, err := w.GetTask(c.taskID)
+		if err != nil || t.State != task.Running || t.ContainerID != c.containerID || t.Health.Unhealthy {
+			// The task stopped or restarted while it was probed.
+			continue
+		}
+
+		s := probeStatus(&t.Health, c.kind)
+		s.Record(c.probe, c.err, now)
+		if c.err != nil {
+			log.Printf("%s probe of task %v failed (%d in a row): %v", c.kind, t.ID, s.Failures, c.err)
+		}
+
+		switch c.kind {
+		case readinessProbe:
+			t.Ready = s.Passing
+		case startupProbe, livenessProbe:
+			if s.Failed(c.probe) {
+				log.Printf("Task %v failed its %s probe", t.ID, c.kind)
+				t.Health.Unhealthy = true
+				t.Health.Reason = c.kind + " probe failed: " + s.LastError
+				t.Ready = false
+			}
+		}
+		w.putTask(t)
+	}
+}
+
+// setReady stores whether t is ready. The caller must hold w.taskMu.
+func (w *Worker) setReady(t *task.Task, ready bool) {
+	if t.Ready == ready {
+		return
+	}
+
+	log.Printf("Task %v is ready: %v", t.ID, ready)
+	t.Ready = ready
+	w.putTask(t)
+}
+
+// probeTarget describes how the probes of t reach it: its ports through
+// t.Address and commands through the runtime.
+func (w *Worker) probeTarget(t task.Task) task.ProbeTarget {
+	return task.ProbeTarget{
+		Address: t.Address,
+		Exec: func(ctx context.Context, cmd []string) (task.ExecResult, error) {
+			return w.Runtime.Exec(ctx, t.ContainerID, cmd)
+		},
+	}
+}
+
+func probeOf(t *task.Task, kind string) *task.Probe {
+	switch kind {
+	case startupProbe:
+		return t.StartupProbe
+	case livenessProbe:
+		return t.Liveness()
+	default:
+		return t.Readiness()
+	}
+}
+
+// probeStatus returns the results of the probe of the given kind.
+func probeStatus(h *task.Health, kind string) *task.ProbeStatus {
+	switch kind {
+	case startupProbe:
+		return &h.Startup
+	case livenessProbe:
+		return &h.Liveness
+	default:
+		return &h.Readiness
+	}
+}
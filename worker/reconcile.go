@@ -0,0 +1,84 @@
+package worker
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/codding-buddha/mini-kube/task"
+	"github.com/google/uuid"
+)
+
+// Reconcile compares the persisted task database with the containers the
+// runtime actually runs. Running containers are re-adopted, tasks whose container is
+// gone are marked failed, tasks whose container exited are finished according
+// to its exit code, and labelled containers the database has
+// no record of are adopted as new tasks so they are not left orphaned.
+func (w *Worker) Reconcile() error {
+	containers, err := w.Runtime.List()
+	if err != nil {
+		return err
+	}
+
+	byTask := make(map[string]int)
+	for i, c := range containers {
+		byTask[c.Labels[task.TaskIDLabel]] = i
+	}
+
+	for _, t := range w.GetTasks() {
+		idx, found := byTask[t.ID.String()]
+		delete(byTask, t.ID.String())
+		if t.State != task.Running && t.State != task.Scheduled && t.State != task.Restarting {
+			continue
+		}
+
+		switch {
+		case !found:
+			log.Printf("Container for task %v no longer exists, marking it failed", t.ID)
+			w.setState(t, task.Failed, task.ReasonContainerMissing, "container is gone after the worker restarted")
+			t.FinishTime = time.Now().UTC()
+		case containers[idx].Status == "running":
+			log.Printf("Re-adopting container %v for task %v", containers[idx].ID, t.ID)
+			t.ContainerID = containers[idx].ID
+			w.setState(t, task.Running, task.ReasonAdopted, fmt.Sprintf("re-adopted container %s", t.ContainerID))
+		default:
+			c := containers[idx]
+			t.ContainerID = c.ID
+			t.FinishTime = time.Now().UTC()
+			// Listing does not report exit codes, so ask for the details.
+			if resp := w.Runtime.Inspect(c.ID); resp.Error == nil && resp.Container != nil && resp.Container.Status == "exited" {
+				err := t.Exited(resp.Container.ExitCode)
+				if err != nil {
+					log.Printf("Error updating task: %v", err)
+				}
+			} else {
+				w.setState(t, task.Failed, task.ReasonError, fmt.Sprintf("container %s is %s", c.ID, c.Status))
+			}
+			log.Printf("Container %v for task %v is %s with exit code %d", c.ID, t.ID, c.Status, t.ExitCode)
+		}
+
+		w.putTask(t)
+	}
+
+	for label, idx := range byTask {
+		c := containers[idx]
+		id, err := uuid.Parse(label)
+		if err != nil || c.Status != "running" {
+			continue
+		}
+
+		log.Printf("Adopting unknown container %v for task %v", c.ID, id)
+		w.putTask(&task.Task{
+			ID:          id,
+			ContainerID: c.ID,
+			Name:        c.Name,
+			Image:       c.Image,
+			State:       task.Running,
+			StartTime:   c.Created,
+			Reason:      task.ReasonAdopted,
+			Message:     fmt.Sprintf("adopted container %s", c.ID),
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package worker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/codding-buddha/mini-kube/node"
+	"github.com/codding-buddha/mini-kube/stats"
+)
+
+// Register announces the worker and its capacity to the manager.
+func (w *Worker) Register() error {
+	reg := node.Registration{
+		Name:    w.Name,
+		Address: w.Address,
+		Stats:   *stats.GetStats(),
+	}
+
+	data, err := json.Marshal(reg)
+	if err != nil {
+		return fmt.Errorf("unable to marshal registration: %v", err)
+	}
+
+	url := fmt.Sprintf("http://%s/nodes", w.Manager)
+	resp, err := http.Post(url, "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		return fmt.Errorf("error connecting to manager %v: %v", w.Manager, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("manager %v rejected registration with status %d", w.Manager, resp.StatusCode)
+	}
+
+	log.Printf("Registered worker %v with manager %v", w.Name, w.Manager)
+	w.setRegistered(true)
+	return nil
+}
+
+func (w *Worker) setRegistered(registered bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.registered = registered
+}
+
+func (w *Worker) isRegistered() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.registered
+}
+
+func (w *Worker) heartbeat() error {
+	data, err := json.Marshal(stats.GetStats())
+	if err != nil {
+		return fmt.Errorf("unable to marshal stats: %v", err)
+	}
+
+	url := fmt.Sprintf("http://%s/nodes/%s/heartbeat", w.Manager, w.Name)
+	resp, err := http.Post(url, "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		return fmt.Errorf("error connecting to manager %v: %v", w.Manager, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		// The manager forgot about us, most likely because it restarted.
+		w.setRegistered(false)
+		return fmt.Errorf("manager %v does not know worker %v", w.Manager, w.Name)
+	}
+
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("manager %v rejected heartbeat with status %d", w.Manager, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// SendHeartbeats registers the worker with its manager and keeps it alive,
+// registering again whenever the manager stops recognising it.
+func (w *Worker) SendHeartbeats() {
+	for {
+		var err error
+		if w.isRegistered() {
+			err = w.heartbeat()
+		} else {
+			err = w.Register()
+		}
+
+		if err != nil {
+			log.Printf("Heartbeat failed: %v", err)
+		}
+
+		if !w.sleep(w.HeartbeatInterval) {
+			return
+		}
+	}
+}
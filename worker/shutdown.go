@@ -0,0 +1,111 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/codding-buddha/mini-kube/task"
+)
+
+// Start runs the worker's loops in the background until Stop is called.
+func (w *Worker) Start() {
+	loops := []func(){
+		w.RunTasks,
+		w.CollectStats,
+		w.UpdateTasks,
+		w.SendHeartbeats,
+		w.ProbeTasks,
+	}
+	for _, loop := range loops {
+		w.loops.Add(1)
+		go func(loop func()) {
+			defer w.loops.Done()
+			loop()
+		}(loop)
+	}
+}
+
+// Draining reports whether the worker is shutting down and refuses new work.
+func (w *Worker) Draining() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.draining
+}
+
+// Stop refuses new work, ends the loops started by Start, runs the task
+// requests still queued and waits for tasks being started or stopped to
+// finish. With StopTasksOnExit running tasks are stopped too; otherwise they
+// keep running and are picked up again when the worker restarts. The task
+// store is closed last. If ctx ends first, Stop returns without closing the
+// store.
+func (w *Worker) Stop(ctx context.Context) error {
+	w.mu.Lock()
+	w.draining = true
+	w.mu.Unlock()
+	w.stopOnce.Do(func() {
+		close(w.done)
+	})
+
+	finished := make(chan struct{})
+	go func() {
+		w.loops.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		return fmt.Errorf("worker loops did not stop: %v", ctx.Err())
+	}
+
+	// Queued requests were already accepted, so the manager expects them to
+	// be carried out.
+	drained := make(chan struct{})
+	go func() {
+		for w.queueLen() > 0 {
+			log.Printf("Running %d queued task requests before exiting", w.queueLen())
+			w.runQueued()
+		}
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		return fmt.Errorf("queued task requests were not all run: %v", ctx.Err())
+	}
+
+	if w.StopTasksOnExit {
+		for _, t := range w.GetTasks() {
+			if ctx.Err() != nil {
+				return fmt.Errorf("tasks were not all stopped: %v", ctx.Err())
+			}
+
+			if t.State == task.Running {
+				log.Printf("Stopping task %v before exiting", t.ID)
+				w.StopTask(*t)
+			}
+		}
+	}
+
+	if c, ok := w.Db.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+// sleep waits for d and reports whether the worker is still running.
+func (w *Worker) sleep(d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-w.done:
+		return false
+	}
+}
@@ -1,11 +1,17 @@
 package worker
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"path/filepath"
+	"sync"
 	"time"
 
+	"github.com/codding-buddha/mini-kube/stats"
+	"github.com/codding-buddha/mini-kube/store"
 	"github.com/codding-buddha/mini-kube/task"
 	"github.com/golang-collections/collections/queue"
 	"github.com/google/uuid"
@@ -14,155 +20,424 @@ import (
 type Worker struct {
 	Name      string
 	Queue     queue.Queue
-	Db        map[uuid.UUID]*task.Task
-	Stats     Stats
+	Db        store.Store
+	Runtime   task.Runtime
+	Stats     stats.Stats
 	TaskCount int
+	// Address is the host:port the manager uses to reach this worker's API.
+	Address string
+	// Manager is the host:port of the manager the worker registers with.
+	Manager string
+	// MaxConcurrentTasks limits how many tasks are started or stopped at once.
+	MaxConcurrentTasks int
+	// RunInterval is how often the queue is checked when nothing wakes the
+	// run loop.
+	RunInterval time.Duration
+	// UpdateInterval is how often task states are checked with the runtime,
+	// StatsInterval how often machine stats are collected and
+	// HeartbeatInterval how often the manager hears from the worker.
+	UpdateInterval    time.Duration
+	StatsInterval     time.Duration
+	HeartbeatInterval time.Duration
+	registered        bool
+	// mu guards Stats, TaskCount, registered and draining.
+	mu sync.Mutex
+	// taskMu serialises read-modify-write updates of stored tasks so the
+	// run loop and the status loop do not overwrite each other.
+	taskMu  sync.Mutex
+	queueMu sync.Mutex
+	wake    chan struct{}
+	// draining makes the API refuse new work while the worker shuts down.
+	draining bool
+	// StopTasksOnExit stops running tasks when the worker shuts down instead
+	// of leaving them for the next worker process to reconcile.
+	StopTasksOnExit bool
+	// done is closed by Stop to end the loops started by Start.
+	done     chan struct{}
+	stopOnce sync.Once
+	loops    sync.WaitGroup
 }
 
-func (w *Worker) InspectTask(t task.Task) task.DockerInspectResponse {
-	config := task.NewConfig(&t)
-	d := task.NewDocker(config)
-	return d.Inspect(t.ContainerID)
+// New creates a worker that runs tasks with rt and keeps its task database
+// in a store of dbType ("memory" or "persistent"). A persistent store is kept
+// in dataDir.
+func New(name string, dbType string, dataDir string, rt task.Runtime) (*Worker, error) {
+	var db store.Store
+	switch dbType {
+	case store.Persistent:
+		filename := filepath.Join(dataDir, fmt.Sprintf("%s_tasks.db", name))
+		s, err := store.NewTaskStore(filename, 0600, "tasks")
+		if err != nil {
+			return nil, fmt.Errorf("unable to create task store: %v", err)
+		}
+		db = s
+	default:
+		db = store.NewInMemoryTaskStore()
+	}
+
+	return &Worker{
+		Name:               name,
+		Queue:              *queue.New(),
+		Db:                 db,
+		Runtime:            rt,
+		MaxConcurrentTasks: 4,
+		RunInterval:        10 * time.Second,
+		UpdateInterval:     15 * time.Second,
+		StatsInterval:      15 * time.Second,
+		HeartbeatInterval:  10 * time.Second,
+		wake:               make(chan struct{}, 1),
+		done:               make(chan struct{}),
+	}, nil
+}
+
+func (w *Worker) InspectTask(t task.Task) task.InspectResponse {
+	return w.Runtime.Inspect(t.ContainerID)
 }
 
+// AddTask queues a task and wakes the run loop.
 func (w *Worker) AddTask(t task.Task) {
+	w.queueMu.Lock()
 	w.Queue.Enqueue(t)
+	w.queueMu.Unlock()
+
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (w *Worker) dequeue() (task.Task, bool) {
+	w.queueMu.Lock()
+	defer w.queueMu.Unlock()
+	if w.Queue.Len() == 0 {
+		return task.Task{}, false
+	}
+
+	return w.Queue.Dequeue().(task.Task), true
+}
+
+func (w *Worker) queueLen() int {
+	w.queueMu.Lock()
+	defer w.queueMu.Unlock()
+	return w.Queue.Len()
 }
 
 // GetTasks return all tasks of the worker.
 func (w *Worker) GetTasks() []*task.Task {
-	tasks := []*task.Task{}
-	for _, task := range w.Db {
-		tasks = append(tasks, task)
+	tasks, err := w.Db.List()
+	if err != nil {
+		log.Printf("Error getting list of tasks: %v", err)
+		return []*task.Task{}
+	}
+
+	return tasks.([]*task.Task)
+}
+
+// GetTask returns the task with the given id from the worker's task database.
+func (w *Worker) GetTask(id uuid.UUID) (*task.Task, error) {
+	t, err := w.Db.Get(id.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return t.(*task.Task), nil
+}
+
+// GetStats returns the stats most recently collected by CollectStats.
+func (w *Worker) GetStats() stats.Stats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.Stats
+}
+
+func (w *Worker) putTask(t *task.Task) {
+	if t.State != task.Running {
+		t.Ready = false
+	}
+
+	err := w.Db.Put(t.ID.String(), t)
+	if err != nil {
+		log.Printf("Error storing task %v: %v", t.ID, err)
+	}
+}
+
+// TaskLogs returns the output of the container running the task with id.
+func (w *Worker) TaskLogs(id uuid.UUID, opts task.LogOptions) (io.ReadCloser, error) {
+	t, err := w.GetTask(id)
+	if err != nil {
+		return nil, err
 	}
 
-	return tasks
+	if t.ContainerID == "" {
+		return nil, fmt.Errorf("task %v has no container", id)
+	}
+
+	return w.Runtime.Logs(t.ContainerID, opts)
 }
 
-func (w *Worker) StartTask(t task.Task) task.DockerResult {
+// ExecTask runs cmd in the container of the running task with id.
+func (w *Worker) ExecTask(ctx context.Context, id uuid.UUID, cmd []string) (task.ExecResult, error) {
+	t, err := w.GetTask(id)
+	if err != nil {
+		return task.ExecResult{}, err
+	}
+
+	if t.State != task.Running || t.ContainerID == "" {
+		return task.ExecResult{}, fmt.Errorf("task %v is not running", id)
+	}
+
+	return w.Runtime.Exec(ctx, t.ContainerID, cmd)
+}
+
+func (w *Worker) StartTask(t task.Task) task.Result {
 	t.StartTime = time.Now().UTC()
 	config := task.NewConfig(&t)
-	d := task.NewDocker(config)
-	result := d.Run()
+	result := w.Runtime.Run(config)
 	if result.Error != nil {
 		log.Printf("Error running task %v:%v\n", t.ID, result.Error)
-		t.State = task.Failed
-		w.Db[t.ID] = &t
+		w.setState(&t, task.Failed, task.ReasonStartError, result.Error.Error())
+		w.storeTask(&t)
 		return result
 	}
 
 	t.ContainerID = result.ContainerId
-	t.State = task.Running
-	w.Db[t.ID] = &t
+	w.setState(&t, task.Running, task.ReasonStarted, fmt.Sprintf("started container %s", t.ContainerID))
+	t.Ready = false
+	t.Health = task.Health{}
+	// Probes need the container's ports and IP before the next update.
+	if resp := w.Runtime.Inspect(t.ContainerID); resp.Container != nil {
+		t.HostPorts = resp.Container.Ports
+		t.IP = resp.Container.IP
+	}
+	w.storeTask(&t)
 	return result
 }
 
-func (w *Worker) StopTask(t task.Task) task.DockerResult {
-	config := task.NewConfig(&t)
-	d := task.NewDocker(config)
-	result := d.Stop(t.ContainerID)
+func (w *Worker) StopTask(t task.Task) task.Result {
+	w.setState(&t, task.Stopping, task.ReasonStopRequested, "")
+	w.storeTask(&t)
+	result := w.stopContainer(t)
+	t.FinishTime = time.Now().UTC()
+	w.setState(&t, task.Completed, task.ReasonStopped, fmt.Sprintf("stopped container %s", t.ContainerID))
+	w.storeTask(&t)
 
+	return result
+}
+
+// RestartTask replaces the container of t with a new one.
+func (w *Worker) RestartTask(t task.Task) task.Result {
+	w.setState(&t, task.Restarting, task.ReasonRestart, t.Message)
+	w.storeTask(&t)
+	result := w.stopContainer(t)
+	if result.Error != nil {
+		log.Printf("Error stopping existing container %v.\n", result.Error)
+	}
+
+	return w.StartTask(t)
+}
+
+func (w *Worker) stopContainer(t task.Task) task.Result {
+	result := w.Runtime.Stop(t.ContainerID)
 	if result.Error != nil {
 		log.Printf("Error stopping container %v:%v", t.ContainerID, result.Error)
+		return result
 	}
-	t.FinishTime = time.Now().UTC()
-	t.State = task.Completed
-	w.Db[t.ID] = &t
-	log.Printf("Stopped and removed container %v for task %v", t.ContainerID, t.ID)
 
+	log.Printf("Stopped and removed container %v for task %v", t.ContainerID, t.ID)
 	return result
 }
 
+// setState moves t to state and logs transitions the state machine refuses.
+func (w *Worker) setState(t *task.Task, state task.State, reason string, message string) {
+	err := t.SetState(state, reason, message)
+	if err != nil {
+		log.Printf("Error updating task: %v", err)
+	}
+}
+
 func (w *Worker) CollectStats() {
 	for {
 		log.Println("Collecting stats")
-		w.Stats = *GetStats()
-		w.TaskCount = w.Stats.TaskCount
-		time.Sleep(15 * time.Second)
+		s := *stats.GetStats()
+		s.TaskCount = w.runningTaskCount()
+		w.mu.Lock()
+		w.Stats = s
+		w.TaskCount = s.TaskCount
+		w.mu.Unlock()
+		if !w.sleep(w.StatsInterval) {
+			return
+		}
 	}
 }
 
+func (w *Worker) runningTaskCount() int {
+	count := 0
+	for _, t := range w.GetTasks() {
+		if t.State == task.Running {
+			count++
+		}
+	}
+
+	return count
+}
+
 func (w *Worker) UpdateTasks() {
 	for {
 		log.Println("Checking status of tasks.")
 		w.updateTasks()
 		log.Println("Task update completed.")
-		log.Println("Sleeping for 15 seconds.")
-		time.Sleep(15 * time.Second)
+		log.Printf("Sleeping for %v.", w.UpdateInterval)
+		if !w.sleep(w.UpdateInterval) {
+			return
+		}
 	}
 }
 
 func (w *Worker) updateTasks() {
-	for id, t := range w.Db {
+	for _, t := range w.GetTasks() {
 		if t.State == task.Running {
 			resp := w.InspectTask(*t)
 			if resp.Error != nil {
 				fmt.Printf("ERROR: %v", resp.Error)
 			}
 
-			if resp.Container == nil {
-				log.Printf("No container for running task %s", id)
-				w.Db[id].State = task.Failed
-			}
+			w.applyInspect(t, resp)
+		}
+	}
 
-			if resp.Container.State.Status == "exited" {
-				log.Printf("Container for task %s in non-running state %s", id, resp.Container.State.Status)
-				w.Db[id].State = task.Failed
-			}
+}
 
-			log.Printf("Running on port %v.\n", resp.Container.NetworkSettings.NetworkSettingsBase.Ports)
+// applyInspect records the inspected container state on the stored copy of t,
+// unless the task was stopped or restarted while it was being inspected.
+func (w *Worker) applyInspect(t *task.Task, resp task.InspectResponse) {
+	w.taskMu.Lock()
+	defer w.taskMu.Unlock()
 
-			w.Db[id].HostPorts = resp.Container.NetworkSettings.NetworkSettingsBase.Ports
+	id := t.ID
+	current, err := w.GetTask(id)
+	if err != nil || current.State != task.Running || current.ContainerID != t.ContainerID {
+		return
+	}
+
+	if resp.Container == nil {
+		log.Printf("No container for running task %s", id)
+		w.setState(current, task.Failed, task.ReasonContainerMissing, fmt.Sprintf("container %s is gone", current.ContainerID))
+		w.putTask(current)
+		return
+	}
+
+	if resp.Container.Status == "exited" {
+		log.Printf("Container for task %s exited with code %d", id, resp.Container.ExitCode)
+		err := current.Exited(resp.Container.ExitCode)
+		if err != nil {
+			log.Printf("Error updating task: %v", err)
 		}
+		current.FinishTime = time.Now().UTC()
 	}
 
+	log.Printf("Running on port %v.\n", resp.Container.Ports)
+
+	current.HostPorts = resp.Container.Ports
+	current.IP = resp.Container.IP
+	w.putTask(current)
+}
+
+// storeTask stores t while holding taskMu.
+func (w *Worker) storeTask(t *task.Task) {
+	w.taskMu.Lock()
+	defer w.taskMu.Unlock()
+	w.putTask(t)
 }
 
+// RunTasks runs queued tasks as soon as they are added, falling back to
+// checking the queue every RunInterval.
 func (w *Worker) RunTasks() {
+	ticker := time.NewTicker(w.RunInterval)
+	defer ticker.Stop()
 	for {
-		if w.Queue.Len() != 0 {
-			result := w.runTask()
-			if result.Error != nil {
-				log.Printf("Error running tasks: %v\n", result.Error)
-			}
-		} else {
-			log.Printf("No tasks to process currently, task queue is empty.\n")
+		select {
+		case <-w.wake:
+		case <-ticker.C:
+		case <-w.done:
+			return
 		}
-		log.Println("Sleeping for 10 seconds")
-		time.Sleep(10 * time.Second)
+
+		w.runQueued()
 	}
 }
 
-func (w *Worker) runTask() task.DockerResult {
-	t := w.Queue.Dequeue()
-	if t == nil {
-		log.Printf("No tasks in the queue")
-		return task.DockerResult{Error: nil}
+// runQueued runs the tasks that were queued when it was called, at most
+// MaxConcurrentTasks at a time. Requests for the same task are handled in
+// order by a single goroutine.
+func (w *Worker) runQueued() {
+	var order []uuid.UUID
+	batches := make(map[uuid.UUID][]task.Task)
+	for i := w.queueLen(); i > 0; i-- {
+		t, ok := w.dequeue()
+		if !ok {
+			break
+		}
+
+		if _, ok := batches[t.ID]; !ok {
+			order = append(order, t.ID)
+		}
+		batches[t.ID] = append(batches[t.ID], t)
+	}
+
+	if len(order) == 0 {
+		log.Printf("No tasks to process currently, task queue is empty.\n")
+		return
 	}
 
-	taskQueued := t.(task.Task)
-	taskPersisted := w.Db[taskQueued.ID]
+	sem := make(chan struct{}, w.MaxConcurrentTasks)
+	var wg sync.WaitGroup
+	for _, id := range order {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(tasks []task.Task) {
+			defer wg.Done()
+			for _, t := range tasks {
+				result := w.processTask(t)
+				if result.Error != nil {
+					log.Printf("Error running tasks: %v\n", result.Error)
+				}
+			}
+			<-sem
+		}(batches[id])
+	}
 
-	if taskPersisted == nil {
+	wg.Wait()
+}
+
+func (w *Worker) processTask(taskQueued task.Task) task.Result {
+	w.taskMu.Lock()
+	taskPersisted, err := w.GetTask(taskQueued.ID)
+
+	if err != nil {
 		taskPersisted = &taskQueued
-		w.Db[taskPersisted.ID] = &taskQueued
+		w.putTask(&taskQueued)
 	}
+	w.taskMu.Unlock()
 
-	var result task.DockerResult
+	var result task.Result
 
 	if task.ValidStateTransition(taskPersisted.State, taskQueued.State) {
+		// The queued copy holds what the manager wants, the persisted one
+		// the state the task is in.
+		t := taskQueued
+		t.State = taskPersisted.State
 		switch taskQueued.State {
 		case task.Scheduled:
-			if taskQueued.ContainerID != "" {
-				result = w.StopTask(taskQueued)
-				if result.Error != nil {
-					log.Printf("Error stopping existing container %v.\n", result.Error)
-				}
+			if t.ContainerID != "" {
+				result = w.RestartTask(t)
+				break
 			}
 
-			result = w.StartTask(taskQueued)
+			result = w.StartTask(t)
+		case task.Restarting:
+			result = w.RestartTask(t)
 		case task.Completed:
-			result = w.StopTask(taskQueued)
+			result = w.StopTask(t)
 		default:
 			result.Error = errors.New("Invalid State! ")
 
@@ -0,0 +1,224 @@
+package worker
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/codding-buddha/mini-kube/task"
+	"github.com/google/uuid"
+)
+
+func newTestWorker(t *testing.T) (*Worker, *task.FakeRuntime) {
+	t.Helper()
+	rt := task.NewFakeRuntime()
+	w, err := New("test-worker", "memory", "", rt)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	return w, rt
+}
+
+func newTask() task.Task {
+	return task.Task{
+		ID:    uuid.New(),
+		Name:  "test",
+		State: task.Scheduled,
+		Image: "busybox",
+	}
+}
+
+// startTask starts a new task on w and returns its stored copy.
+func startTask(t *testing.T, w *Worker) *task.Task {
+	t.Helper()
+	tk := newTask()
+	result := w.StartTask(tk)
+	if result.Error != nil {
+		t.Fatalf("StartTask: %v", result.Error)
+	}
+
+	return getTask(t, w, tk.ID)
+}
+
+func getTask(t *testing.T, w *Worker, id uuid.UUID) *task.Task {
+	t.Helper()
+	stored, err := w.GetTask(id)
+	if err != nil {
+		t.Fatalf("GetTask(%v): %v", id, err)
+	}
+
+	return stored
+}
+
+func TestStartTask(t *testing.T) {
+	w, rt := newTestWorker(t)
+	stored := startTask(t, w)
+
+	if stored.State != task.Running || stored.Reason != task.ReasonStarted {
+		t.Errorf("state = %v (%s), want Running (%s)", stored.State, stored.Reason, task.ReasonStarted)
+	}
+	if stored.ContainerID == "" {
+		t.Fatal("ContainerID is empty")
+	}
+	if stored.IP == "" {
+		t.Error("IP was not filled in from the runtime")
+	}
+	if stored.StartTime.IsZero() {
+		t.Error("StartTime was not set")
+	}
+	if resp := rt.Inspect(stored.ContainerID); resp.Error != nil || resp.Container.Status != "running" {
+		t.Errorf("container is not running: %+v", resp)
+	}
+}
+
+func TestStartTaskRunError(t *testing.T) {
+	w, rt := newTestWorker(t)
+	rt.RunError = errors.New("image not found")
+	tk := newTask()
+
+	result := w.StartTask(tk)
+	if result.Error == nil {
+		t.Fatal("StartTask succeeded, want an error")
+	}
+
+	stored := getTask(t, w, tk.ID)
+	if stored.State != task.Failed || stored.Reason != task.ReasonStartError {
+		t.Errorf("state = %v (%s), want Failed (%s)", stored.State, stored.Reason, task.ReasonStartError)
+	}
+	if stored.Message != "image not found" {
+		t.Errorf("message = %q, want the runtime error", stored.Message)
+	}
+}
+
+func TestStopTask(t *testing.T) {
+	w, rt := newTestWorker(t)
+	stored := startTask(t, w)
+
+	result := w.StopTask(*stored)
+	if result.Error != nil {
+		t.Fatalf("StopTask: %v", result.Error)
+	}
+
+	stopped := getTask(t, w, stored.ID)
+	if stopped.State != task.Completed || stopped.Reason != task.ReasonStopped {
+		t.Errorf("state = %v (%s), want Completed (%s)", stopped.State, stopped.Reason, task.ReasonStopped)
+	}
+	if stopped.Succeeded() {
+		t.Error("a stopped task counts as succeeded")
+	}
+	if stopped.FinishTime.IsZero() {
+		t.Error("FinishTime was not set")
+	}
+	if resp := rt.Inspect(stored.ContainerID); resp.Error == nil {
+		t.Error("container still exists after StopTask")
+	}
+}
+
+func TestStopFailedTask(t *testing.T) {
+	w, rt := newTestWorker(t)
+	stored := startTask(t, w)
+	rt.Exit(stored.ContainerID, 1)
+	w.updateTasks()
+
+	failed := getTask(t, w, stored.ID)
+	if failed.State != task.Failed {
+		t.Fatalf("state = %v, want Failed", failed.State)
+	}
+
+	stop := *failed
+	stop.State = task.Completed
+	result := w.processTask(stop)
+	if result.Error != nil {
+		t.Fatalf("processTask: %v", result.Error)
+	}
+
+	stopped := getTask(t, w, stored.ID)
+	if stopped.State != task.Completed || stopped.Reason != task.ReasonStopped {
+		t.Errorf("state = %v (%s), want Completed (%s)", stopped.State, stopped.Reason, task.ReasonStopped)
+	}
+}
+
+func TestUpdateTasks(t *testing.T) {
+	tests := []struct {
+		name     string
+		exit     func(rt *task.FakeRuntime, id string)
+		state    task.State
+		reason   string
+		exitCode int
+		finished bool
+	}{
+		{
+			name:   "still running",
+			exit:   func(rt *task.FakeRuntime, id string) {},
+			state:  task.Running,
+			reason: task.ReasonStarted,
+		},
+		{
+			name:     "exit 0",
+			exit:     func(rt *task.FakeRuntime, id string) { rt.Exit(id, 0) },
+			state:    task.Completed,
+			reason:   task.ReasonCompleted,
+			finished: true,
+		},
+		{
+			name:     "non-zero exit",
+			exit:     func(rt *task.FakeRuntime, id string) { rt.Exit(id, 3) },
+			state:    task.Failed,
+			reason:   task.ReasonError,
+			exitCode: 3,
+			finished: true,
+		},
+		{
+			name:     "crash",
+			exit:     func(rt *task.FakeRuntime, id string) { rt.Crash(id) },
+			state:    task.Failed,
+			reason:   task.ReasonError,
+			exitCode: 137,
+			finished: true,
+		},
+		{
+			name:   "missing container",
+			exit:   func(rt *task.FakeRuntime, id string) { rt.Remove(id) },
+			state:  task.Failed,
+			reason: task.ReasonContainerMissing,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, rt := newTestWorker(t)
+			stored := startTask(t, w)
+
+			tt.exit(rt, stored.ContainerID)
+			w.updateTasks()
+
+			updated := getTask(t, w, stored.ID)
+			if updated.State != tt.state || updated.Reason != tt.reason {
+				t.Errorf("state = %v (%s), want %v (%s)", updated.State, updated.Reason, tt.state, tt.reason)
+			}
+			if updated.ExitCode != tt.exitCode {
+				t.Errorf("exit code = %d, want %d", updated.ExitCode, tt.exitCode)
+			}
+			if updated.Succeeded() != (tt.reason == task.ReasonCompleted) {
+				t.Errorf("Succeeded() = %v for reason %s", updated.Succeeded(), updated.Reason)
+			}
+			if updated.FinishTime.IsZero() == tt.finished {
+				t.Errorf("FinishTime = %v, want it set: %v", updated.FinishTime, tt.finished)
+			}
+		})
+	}
+}
+
+func TestUpdateTasksIgnoresStoppedTasks(t *testing.T) {
+	w, _ := newTestWorker(t)
+	stored := startTask(t, w)
+	w.StopTask(*stored)
+
+	// The container is gone, but the task was stopped on purpose.
+	w.updateTasks()
+
+	stopped := getTask(t, w, stored.ID)
+	if stopped.State != task.Completed || stopped.Reason != task.ReasonStopped {
+		t.Errorf("state = %v (%s), want Completed (%s)", stopped.State, stopped.Reason, task.ReasonStopped)
+	}
+}